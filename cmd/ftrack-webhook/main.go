@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"github.com/conducte/ftrack-golang-api/ftrack/webhook"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run returns errors instead of exiting so the deferred cleanups run.
+func run() error {
+	configPath := flag.String("config", "ftrack-webhook.json", "Path of the JSON config with the routes")
+	apiKey := flag.String("api_key", os.Getenv("FTRACK_API_KEY"), "Ftrack Api Key from Settings -> Api Keys")
+	apiUser := flag.String("api_user", os.Getenv("FTRACK_API_USER"), "Ftrack Api User username from enabled user")
+	serverUrl := flag.String("server_url", os.Getenv("FTRACK_SERVER"), "Ftrack Server Url server url eg https://ftrack.com")
+	flag.Parse()
+
+	config, err := webhook.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	session, err := ftrack.NewSession(ftrack.SessionConfig{
+		ApiKey:    *apiKey,
+		ApiUser:   *apiUser,
+		ServerUrl: *serverUrl,
+	})
+	if err != nil {
+		return err
+	}
+
+	onConnect := func() { log.Println("Connected to event hub") }
+	onDisconnect := func(err error) { log.Println("Disconnected from event hub:", err) }
+	hub := event.NewEventHub(session, event.EventHubOptions{OnConnect: &onConnect, OnDisconnect: &onDisconnect})
+	connected := hub.IsConnected
+	onError := func(route string, e *event.Event, err error) {
+		log.Printf("Route %s failed to deliver event %s: %s", route, e.Id, err)
+	}
+	onDeadLetter := func(letter *webhook.DeadLetter) {
+		log.Printf("Route %s dead-lettered event %s after %d attempts: %s", letter.Route, letter.Event.Id, letter.Attempts, letter.Error)
+	}
+	forwarder, err := webhook.NewForwarder(config, webhook.ForwarderOptions{
+		Connected:    &connected,
+		OnError:      &onError,
+		OnDeadLetter: &onDeadLetter,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = forwarder.Close() }()
+	// Subscribe before connecting so the subscriptions are sent on connect.
+	if err := forwarder.Subscribe(hub); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	defer func() { _ = hub.Close() }()
+	// Connect in the background so that the health endpoint reports the
+	// missing connection and the events left from the previous run are
+	// delivered while ftrack is unreachable. Connecting only fails once ctx
+	// is done or the hub is closed.
+	go func() { _ = hub.ConnectWithRetry(ctx) }()
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", forwarder)
+	server := &http.Server{Addr: config.HealthAddress, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("Health endpoint failed:", err)
+			cancel()
+		}
+	}()
+	log.Printf("Forwarding %d routes, health on %s/health", len(config.Routes), config.HealthAddress)
+
+	err = forwarder.Run(ctx)
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	_ = server.Shutdown(shutdown)
+	return err
+}
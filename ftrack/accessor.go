@@ -0,0 +1,209 @@
+package ftrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskAccessor stores data on a filesystem below Prefix. Writes go to a
+// temporary file that is renamed into place once complete, so readers never
+// see partially written files.
+type DiskAccessor struct {
+	Prefix string
+	// FileMode of written files, defaults to 0644.
+	FileMode os.FileMode
+}
+
+func (accessor *DiskAccessor) GetFilesystemPath(resourceIdentifier string) (string, error) {
+	if resourceIdentifier == "" {
+		return "", errors.New("empty resource identifier")
+	}
+	if accessor.Prefix == "" {
+		return filepath.FromSlash(resourceIdentifier), nil
+	}
+	if filepath.IsAbs(filepath.FromSlash(resourceIdentifier)) {
+		return "", errors.New(fmt.Sprintf("resource identifier %s is an absolute path", resourceIdentifier))
+	}
+	// Join cleans the path, identifiers with .. must still resolve below
+	// Prefix.
+	path := filepath.Join(accessor.Prefix, filepath.FromSlash(resourceIdentifier))
+	relative, err := filepath.Rel(filepath.Clean(accessor.Prefix), path)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", errors.New(fmt.Sprintf("resource identifier %s is outside of %s", resourceIdentifier, accessor.Prefix))
+	}
+	return path, nil
+}
+
+func (accessor *DiskAccessor) Open(ctx context.Context, resourceIdentifier string) (io.ReadCloser, error) {
+	path, err := accessor.GetFilesystemPath(resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (accessor *DiskAccessor) Write(ctx context.Context, resourceIdentifier string, reader io.Reader, size int64) error {
+	path, err := accessor.GetFilesystemPath(resourceIdentifier)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, &contextReader{ctx: ctx, Reader: reader})
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size >= 0 && written != size {
+		err = errors.New(fmt.Sprintf("wrote %d bytes to %s, expected %d", written, path, size))
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), accessor.fileMode())
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+func (accessor *DiskAccessor) fileMode() os.FileMode {
+	if accessor.FileMode == 0 {
+		return 0644
+	}
+	return accessor.FileMode
+}
+
+func (accessor *DiskAccessor) Exists(ctx context.Context, resourceIdentifier string) (bool, error) {
+	path, err := accessor.GetFilesystemPath(resourceIdentifier)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (accessor *DiskAccessor) Remove(ctx context.Context, resourceIdentifier string) error {
+	path, err := accessor.GetFilesystemPath(resourceIdentifier)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ServerAccessor reads and writes data in the ftrack server location, where
+// resource identifiers are component ids.
+type ServerAccessor struct {
+	Session *Session
+}
+
+func (accessor *ServerAccessor) GetFilesystemPath(resourceIdentifier string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (accessor *ServerAccessor) Open(ctx context.Context, resourceIdentifier string) (io.ReadCloser, error) {
+	id, err := uuid.FromString(resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	componentUrl, err := accessor.Session.GetSignedComponentUrl(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", componentUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := accessor.Session.transferClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return nil, newResponseError(response)
+	}
+	return response.Body, nil
+}
+
+func (accessor *ServerAccessor) Write(ctx context.Context, resourceIdentifier string, reader io.Reader, size int64) error {
+	id, err := uuid.FromString(resourceIdentifier)
+	if err != nil {
+		return err
+	}
+	result, err := accessor.Session.Query(fmt.Sprintf("select name, file_type from Component where id is %s", id))
+	if err != nil {
+		return err
+	}
+	if len(result.Data) == 0 {
+		return errors.New(fmt.Sprintf("component %s not found", id))
+	}
+	name, _ := result.Data[0]["name"].(string)
+	fileType, _ := result.Data[0]["file_type"].(string)
+	results, err := accessor.Session.Call(NewGetUploadMetadataOperation(name+fileType, size, id))
+	if err != nil {
+		return err
+	}
+	return accessor.Session.upload(ctx, results[0].(GetUploadMetadataResult), io.LimitReader(reader, size), size)
+}
+
+func (accessor *ServerAccessor) Exists(ctx context.Context, resourceIdentifier string) (bool, error) {
+	id, err := uuid.FromString(resourceIdentifier)
+	if err != nil {
+		return false, err
+	}
+	componentUrl, err := accessor.Session.GetSignedComponentUrl(id, 0)
+	if err != nil {
+		return false, err
+	}
+	request, err := http.NewRequestWithContext(ctx, "HEAD", componentUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	response, err := accessor.Session.transferClient().Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	return response.StatusCode == http.StatusOK, nil
+}
+
+// Remove is a no-op, the server removes data once the component is deleted.
+func (accessor *ServerAccessor) Remove(ctx context.Context, resourceIdentifier string) error {
+	return nil
+}
+
+type contextReader struct {
+	io.Reader
+	ctx context.Context
+}
+
+func (reader *contextReader) Read(p []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return reader.Reader.Read(p)
+}
@@ -0,0 +1,244 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"strings"
+	"sync"
+)
+
+const (
+	TopicDiscover = "ftrack.action.discover"
+	TopicLaunch   = "ftrack.action.launch"
+)
+
+// Hub is the part of an event hub actions need.
+type Hub interface {
+	Subscribe(subscription string, handler event.Handler) (string, error)
+	Unsubscribe(subscriberId string) error
+}
+
+var _ Hub = (*event.EventHub)(nil)
+
+// Item is an entry offered in the actions menu.
+type Item struct {
+	Label            string `json:"label"`
+	Variant          string `json:"variant,omitempty"`
+	Description      string `json:"description,omitempty"`
+	Icon             string `json:"icon,omitempty"`
+	ActionIdentifier string `json:"actionIdentifier"`
+	// ActionData is merged into the item, ftrack passes the extra keys of an
+	// item back at the top level of the launch event data.
+	ActionData map[string]interface{} `json:"-"`
+}
+
+func (item Item) MarshalJSON() ([]byte, error) {
+	type fields Item
+	encoded, err := json.Marshal(fields(item))
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	for key, value := range item.ActionData {
+		data[key] = value
+	}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+type Result struct {
+	Success bool
+	Message string
+	// Items requests a form from the user instead of finishing the launch.
+	Items             []UIItem
+	Title             string
+	SubmitButtonLabel string
+}
+
+func (result *Result) data() map[string]interface{} {
+	if len(result.Items) > 0 {
+		data := map[string]interface{}{"items": result.Items}
+		if result.Title != "" {
+			data["title"] = result.Title
+		}
+		if result.SubmitButtonLabel != "" {
+			data["submit_button_label"] = result.SubmitButtonLabel
+		}
+		return data
+	}
+	return map[string]interface{}{"success": result.Success, "message": result.Message}
+}
+
+type Action struct {
+	Identifier  string
+	Label       string
+	Variant     string
+	Description string
+	Icon        string
+	// Discover returns the items offered for the discover event. When nil the
+	// action offers a single item built from its label for every selection.
+	Discover func(event *event.Event) []Item
+	Launch   func(event *event.Event) (*Result, error)
+}
+
+func (action *Action) Item() Item {
+	return Item{
+		Label:            action.Label,
+		Variant:          action.Variant,
+		Description:      action.Description,
+		Icon:             action.Icon,
+		ActionIdentifier: action.Identifier,
+	}
+}
+
+func (action *Action) discover(e *event.Event) map[string]interface{} {
+	items := []Item{action.Item()}
+	if action.Discover != nil {
+		items = action.Discover(e)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		if items[i].ActionIdentifier == "" {
+			items[i].ActionIdentifier = action.Identifier
+		}
+	}
+	return map[string]interface{}{"items": items}
+}
+
+func (action *Action) launch(e *event.Event) map[string]interface{} {
+	result, err := action.Launch(e)
+	if err != nil {
+		return (&Result{Success: false, Message: err.Error()}).data()
+	}
+	if result == nil {
+		result = &Result{Success: true}
+	}
+	return result.data()
+}
+
+type Selection struct {
+	EntityId   string `json:"entityId"`
+	EntityType string `json:"entityType"`
+}
+
+// GetSelection returns the entities selected when the action was discovered
+// or launched.
+func GetSelection(e *event.Event) []Selection {
+	var selection []Selection
+	encoded, err := json.Marshal(e.Data["selection"])
+	if err != nil {
+		return nil
+	}
+	_ = json.Unmarshal(encoded, &selection)
+	return selection
+}
+
+// GetValues returns the values submitted with a form, nil when the launch
+// event does not come from a form.
+func GetValues(e *event.Event) map[string]interface{} {
+	values, _ := e.Data["values"].(map[string]interface{})
+	return values
+}
+
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Runner hosts actions on an event hub, subscribing each to the discover
+// events and to the launch events carrying its identifier.
+type Runner struct {
+	hub Hub
+	// username limits the actions to events published by that user, all
+	// users when empty.
+	username      string
+	mu            sync.Mutex
+	actions       map[string]*Action
+	subscriptions map[string][]string
+}
+
+func NewRunner(hub Hub, username string) *Runner {
+	return &Runner{
+		hub:           hub,
+		username:      username,
+		actions:       map[string]*Action{},
+		subscriptions: map[string][]string{},
+	}
+}
+
+func (runner *Runner) subscription(topic string, filters ...string) string {
+	parts := append([]string{"topic=" + topic}, filters...)
+	if runner.username != "" {
+		parts = append(parts, "source.user.username="+quote(runner.username))
+	}
+	return strings.Join(parts, " and ")
+}
+
+func (runner *Runner) Register(action *Action) error {
+	if action.Identifier == "" {
+		return errors.New("action has no identifier")
+	}
+	if action.Launch == nil {
+		return errors.New(fmt.Sprintf("action %s has no launch hook", action.Identifier))
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if _, ok := runner.actions[action.Identifier]; ok {
+		return errors.New(fmt.Sprintf("action %s is already registered", action.Identifier))
+	}
+	discoverId, err := runner.hub.Subscribe(runner.subscription(TopicDiscover), action.discover)
+	if err != nil {
+		return err
+	}
+	launchId, err := runner.hub.Subscribe(
+		runner.subscription(TopicLaunch, "data.actionIdentifier="+quote(action.Identifier)),
+		action.launch,
+	)
+	if err != nil {
+		_ = runner.hub.Unsubscribe(discoverId)
+		return err
+	}
+	runner.actions[action.Identifier] = action
+	runner.subscriptions[action.Identifier] = []string{discoverId, launchId}
+	return nil
+}
+
+func (runner *Runner) Unregister(identifier string) error {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	subscriptions, ok := runner.subscriptions[identifier]
+	if !ok {
+		return errors.New(fmt.Sprintf("action %s is not registered", identifier))
+	}
+	delete(runner.actions, identifier)
+	delete(runner.subscriptions, identifier)
+	var err error
+	for _, subscriberId := range subscriptions {
+		if unsubscribeErr := runner.hub.Unsubscribe(subscriberId); unsubscribeErr != nil {
+			err = unsubscribeErr
+		}
+	}
+	return err
+}
+
+// Close unregisters all actions.
+func (runner *Runner) Close() error {
+	runner.mu.Lock()
+	var identifiers []string
+	for identifier := range runner.actions {
+		identifiers = append(identifiers, identifier)
+	}
+	runner.mu.Unlock()
+	var err error
+	for _, identifier := range identifiers {
+		if unregisterErr := runner.Unregister(identifier); unregisterErr != nil {
+			err = unregisterErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeHub struct {
+	subscriptions map[string]string
+	handlers      map[string]event.Handler
+	count         int
+}
+
+func newFakeHub() *fakeHub {
+	return &fakeHub{subscriptions: map[string]string{}, handlers: map[string]event.Handler{}}
+}
+
+func (hub *fakeHub) Subscribe(subscription string, handler event.Handler) (string, error) {
+	if _, err := event.ParseExpression(subscription); err != nil {
+		return "", err
+	}
+	hub.count++
+	id := fmt.Sprintf("subscriber-%d", hub.count)
+	hub.subscriptions[id] = subscription
+	hub.handlers[id] = handler
+	return id, nil
+}
+
+func (hub *fakeHub) Unsubscribe(subscriberId string) error {
+	delete(hub.subscriptions, subscriberId)
+	delete(hub.handlers, subscriberId)
+	return nil
+}
+
+// publish returns the replies of all matching handlers.
+func (hub *fakeHub) publish(e *event.Event) []map[string]interface{} {
+	var replies []map[string]interface{}
+	for id, subscription := range hub.subscriptions {
+		if event.MustParseExpression(subscription).Match(e.Payload()) {
+			if reply := hub.handlers[id](e); reply != nil {
+				replies = append(replies, reply)
+			}
+		}
+	}
+	return replies
+}
+
+func newActionEvent(topic string, username string, data map[string]interface{}) *event.Event {
+	e := event.NewEvent(topic, data)
+	e.Source = &event.Source{User: &event.User{Username: username}}
+	return e
+}
+
+func TestRunner(t *testing.T) {
+	hub := newFakeHub()
+	runner := NewRunner(hub, "john.doe")
+	var launched []map[string]interface{}
+	publish := &Action{
+		Identifier: "com.example.publish",
+		Label:      "Publish",
+		Launch: func(e *event.Event) (*Result, error) {
+			values := GetValues(e)
+			if values == nil {
+				return &Result{
+					Title: "Publish",
+					Items: []UIItem{
+						Enumerator("format", "Format", []EnumeratorOption{{Label: "EXR", Value: "exr"}}, "exr"),
+						Text("comment", "Comment", ""),
+						Number("frames", "Frames", 100),
+						Boolean("notify", "Notify", true),
+					},
+				}, nil
+			}
+			launched = append(launched, values)
+			return &Result{Success: true, Message: "Published"}, nil
+		},
+	}
+	review := &Action{
+		Identifier: "com.example.review",
+		Discover: func(e *event.Event) []Item {
+			selection := GetSelection(e)
+			if len(selection) != 1 || selection[0].EntityType != "task" {
+				return nil
+			}
+			return []Item{{Label: "Review", Variant: "Daily"}}
+		},
+		Launch: func(e *event.Event) (*Result, error) {
+			return nil, errors.New("review failed")
+		},
+	}
+	assert.Nil(t, runner.Register(publish))
+	assert.Nil(t, runner.Register(review))
+	assert.NotNil(t, runner.Register(publish))
+	assert.NotNil(t, runner.Register(&Action{Identifier: "com.example.empty"}))
+
+	selection := []interface{}{map[string]interface{}{"entityId": "1", "entityType": "task"}}
+	replies := hub.publish(newActionEvent(TopicDiscover, "john.doe", map[string]interface{}{"selection": selection}))
+	assert.Len(t, replies, 2)
+	var identifiers []string
+	for _, reply := range replies {
+		for _, item := range reply["items"].([]Item) {
+			identifiers = append(identifiers, item.ActionIdentifier)
+		}
+	}
+	assert.ElementsMatch(t, []string{"com.example.publish", "com.example.review"}, identifiers)
+
+	replies = hub.publish(newActionEvent(TopicDiscover, "jane.doe", map[string]interface{}{"selection": selection}))
+	assert.Len(t, replies, 0)
+	replies = hub.publish(newActionEvent(TopicDiscover, "john.doe", map[string]interface{}{"selection": []interface{}{}}))
+	assert.Len(t, replies, 1)
+
+	replies = hub.publish(newActionEvent(TopicLaunch, "john.doe", map[string]interface{}{
+		"actionIdentifier": "com.example.publish",
+	}))
+	assert.Len(t, replies, 1)
+	items := replies[0]["items"].([]UIItem)
+	assert.Len(t, items, 4)
+	assert.Equal(t, "enumerator", items[0].Type)
+	assert.Equal(t, "Publish", replies[0]["title"])
+
+	replies = hub.publish(newActionEvent(TopicLaunch, "john.doe", map[string]interface{}{
+		"actionIdentifier": "com.example.publish",
+		"values":           map[string]interface{}{"format": "exr", "notify": true},
+	}))
+	assert.Equal(t, []map[string]interface{}{{"success": true, "message": "Published"}}, replies)
+	assert.Equal(t, "exr", launched[0]["format"])
+
+	replies = hub.publish(newActionEvent(TopicLaunch, "john.doe", map[string]interface{}{
+		"actionIdentifier": "com.example.review",
+	}))
+	assert.Equal(t, []map[string]interface{}{{"success": false, "message": "review failed"}}, replies)
+
+	assert.Nil(t, runner.Unregister("com.example.review"))
+	assert.NotNil(t, runner.Unregister("com.example.review"))
+	assert.Len(t, hub.subscriptions, 2)
+	assert.Nil(t, runner.Close())
+	assert.Len(t, hub.subscriptions, 0)
+}
+
+func TestItem_MarshalJSON(t *testing.T) {
+	encoded, err := json.Marshal(Item{
+		Label:            "Open",
+		ActionIdentifier: "com.example.open",
+		ActionData:       map[string]interface{}{"application": "nuke", "label": "ignored"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{"label": "Open", "actionIdentifier": "com.example.open", "application": "nuke"}`, string(encoded))
+}
+
+func TestRunner_QuotesIdentifiers(t *testing.T) {
+	hub := newFakeHub()
+	runner := NewRunner(hub, `odd "user"`)
+	assert.Nil(t, runner.Register(&Action{
+		Identifier: `with space and "quotes"`,
+		Launch:     func(e *event.Event) (*Result, error) { return nil, nil },
+	}))
+	replies := hub.publish(newActionEvent(TopicLaunch, `odd "user"`, map[string]interface{}{
+		"actionIdentifier": `with space and "quotes"`,
+	}))
+	assert.Equal(t, []map[string]interface{}{{"success": true, "message": ""}}, replies)
+}
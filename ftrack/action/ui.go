@@ -0,0 +1,45 @@
+package action
+
+// UIItem is a widget of an action form. Launch is called again with the
+// submitted form values once the user confirms the form.
+type UIItem struct {
+	Type  string             `json:"type"`
+	Name  string             `json:"name,omitempty"`
+	Label string             `json:"label,omitempty"`
+	Value interface{}        `json:"value"`
+	Data  []EnumeratorOption `json:"data,omitempty"`
+}
+
+type EnumeratorOption struct {
+	Label string      `json:"label"`
+	Value interface{} `json:"value"`
+}
+
+func Enumerator(name string, label string, options []EnumeratorOption, value interface{}) UIItem {
+	return UIItem{Type: "enumerator", Name: name, Label: label, Data: options, Value: value}
+}
+
+func Text(name string, label string, value string) UIItem {
+	return UIItem{Type: "text", Name: name, Label: label, Value: value}
+}
+
+func TextArea(name string, label string, value string) UIItem {
+	return UIItem{Type: "textarea", Name: name, Label: label, Value: value}
+}
+
+func Number(name string, label string, value float64) UIItem {
+	return UIItem{Type: "number", Name: name, Label: label, Value: value}
+}
+
+func Boolean(name string, label string, value bool) UIItem {
+	return UIItem{Type: "boolean", Name: name, Label: label, Value: value}
+}
+
+func Hidden(name string, value interface{}) UIItem {
+	return UIItem{Type: "hidden", Name: name, Value: value}
+}
+
+// Label displays markdown text in the form.
+func Label(text string) UIItem {
+	return UIItem{Type: "label", Value: text}
+}
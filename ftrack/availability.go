@@ -0,0 +1,122 @@
+package ftrack
+
+import (
+	"fmt"
+	"strings"
+)
+
+const availabilityBatchSize int = 100
+
+// GetComponentAvailability returns the availability of each component in each
+// location as a percentage keyed by component id and location id. File
+// components are either 0 or 100 available, containers such as sequences are
+// as available as the share of their members present in the location.
+func (session *Session) GetComponentAvailability(components []map[string]interface{}, locations []Location) (map[string]map[string]float64, error) {
+	var componentIds []string
+	for _, component := range components {
+		id, err := componentId(component)
+		if err != nil {
+			return nil, err
+		}
+		componentIds = append(componentIds, id)
+	}
+	var locationIds []string
+	for _, location := range locations {
+		locationIds = append(locationIds, location.Id())
+	}
+	availability := map[string]map[string]float64{}
+	for _, id := range componentIds {
+		availability[id] = map[string]float64{}
+		for _, locationId := range locationIds {
+			availability[id][locationId] = 0
+		}
+	}
+	if len(componentIds) == 0 || len(locationIds) == 0 {
+		return availability, nil
+	}
+
+	members := map[string][]string{}
+	memberData, err := session.queryInBatches("select id, container_id from Component where container_id in (%s)", componentIds)
+	if err != nil {
+		return nil, err
+	}
+	queryIds := append([]string(nil), componentIds...)
+	for _, member := range memberData {
+		id, _ := member["id"].(string)
+		containerId, _ := member["container_id"].(string)
+		members[containerId] = append(members[containerId], id)
+		queryIds = append(queryIds, id)
+	}
+
+	present := map[string]map[string]bool{}
+	componentLocations, err := session.queryInBatches(
+		fmt.Sprintf(
+			"select component_id, location_id from ComponentLocation where component_id in (%%s) and location_id in (%s)",
+			quoteIds(locationIds),
+		),
+		queryIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, componentLocation := range componentLocations {
+		id, _ := componentLocation["component_id"].(string)
+		locationId, _ := componentLocation["location_id"].(string)
+		if present[id] == nil {
+			present[id] = map[string]bool{}
+		}
+		present[id][locationId] = true
+	}
+
+	for _, id := range componentIds {
+		for _, locationId := range locationIds {
+			if len(members[id]) == 0 {
+				if present[id][locationId] {
+					availability[id][locationId] = 100
+				}
+				continue
+			}
+			count := 0
+			for _, member := range members[id] {
+				if present[member][locationId] {
+					count++
+				}
+			}
+			availability[id][locationId] = float64(count) / float64(len(members[id])) * 100
+		}
+	}
+	return availability, nil
+}
+
+// queryInBatches runs expression, which has a single %s placeholder for a
+// list of ids, once for each batch of ids in a single call.
+func (session *Session) queryInBatches(expression string, ids []string) ([]map[string]interface{}, error) {
+	var operations []interface{}
+	for start := 0; start < len(ids); start += availabilityBatchSize {
+		end := start + availabilityBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		operations = append(operations, NewQueryOperation(fmt.Sprintf(expression, quoteIds(ids[start:end]))))
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	results, err := session.Call(operations...)
+	if err != nil {
+		return nil, err
+	}
+	var data []map[string]interface{}
+	for _, result := range results {
+		data = append(data, result.(QueryResult).Data...)
+	}
+	return data, nil
+}
+
+func quoteIds(ids []string) string {
+	var quoted []string
+	for _, id := range ids {
+		quoted = append(quoted, fmt.Sprintf("\"%s\"", id))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,54 @@
+package ftrack
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSession_GetComponentAvailability(t *testing.T) {
+	members := []string{"m1", "m2", "m3", "m4"}
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			expression := op["expression"].(string)
+			var data []interface{}
+			switch {
+			case strings.Contains(expression, "from Component where container_id in"):
+				for _, member := range members {
+					if strings.Contains(expression, `"sequence"`) {
+						data = append(data, map[string]interface{}{"id": member, "container_id": "sequence"})
+					}
+				}
+			case strings.Contains(expression, "from ComponentLocation"):
+				for _, row := range [][2]string{
+					{"file", "server"}, {"m1", "server"}, {"m2", "server"}, {"m3", "server"},
+					{"m1", "disk"}, {"sequence", "disk"},
+				} {
+					if strings.Contains(expression, fmt.Sprintf(`"%s"`, row[0])) {
+						data = append(data, map[string]interface{}{"component_id": row[0], "location_id": row[1]})
+					}
+				}
+			}
+			results = append(results, map[string]interface{}{"action": "query", "data": data})
+		}
+		return results
+	})
+	availability, err := session.GetComponentAvailability(
+		[]map[string]interface{}{{"id": "file"}, {"id": "sequence"}, {"id": "missing"}},
+		[]Location{
+			NewLocation("server", "server", nil, nil),
+			NewLocation("disk", "disk", nil, nil),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]map[string]float64{
+		"file":     {"server": 100, "disk": 0},
+		"sequence": {"server": 75, "disk": 25},
+		"missing":  {"server": 0, "disk": 0},
+	}, availability)
+	assert.Len(t, server.Batches(), 2)
+}
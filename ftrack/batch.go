@@ -0,0 +1,178 @@
+package ftrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var operationIndexPattern = regexp.MustCompile(`(?i)\boperations?\s*(?:#|index|no\.?)?\s*\[?(\d+)\]?`)
+
+type CallOptions struct {
+	// IsolateFailure re-runs a failed batch in halves until the failing
+	// operation is found. Operations in sub-batches that succeed are
+	// committed by the server, so only enable it for batches that are safe
+	// to apply partially, e.g. queries or idempotent updates.
+	IsolateFailure bool
+}
+
+type operationDescription struct {
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entity_type"`
+	EntityKey  []string               `json:"entity_key"`
+	EntityData map[string]interface{} `json:"entity_data"`
+}
+
+// CallWithOptions runs operations like Call. Server errors of batches of more
+// than one operation are returned as a *BatchError naming the failing
+// operation, guessed from the error message unless IsolateFailure is set.
+func (session *Session) CallWithOptions(options CallOptions, operations ...interface{}) ([]interface{}, error) {
+	result, err := session.Call(operations...)
+	if err == nil {
+		return result, nil
+	}
+	if len(operations) < 2 || !isServerError(err) {
+		return nil, err
+	}
+	if options.IsolateFailure {
+		// The index guessed from the message is only a hint, the failing
+		// operation is confirmed by running the batch in parts.
+		if isolated := session.isolateFailure(operations, 0, len(operations), err); isolated != nil {
+			return nil, isolated
+		}
+	}
+	return nil, session.newBatchError(operations, -1, err)
+}
+
+// isolateFailure bisects operations[low:high], which is known to fail, until
+// a single failing operation remains.
+func (session *Session) isolateFailure(operations []interface{}, low int, high int, err error) *BatchError {
+	if high-low == 1 {
+		batchError := session.newBatchError(operations, low, err)
+		batchError.Isolated = true
+		return batchError
+	}
+	middle := low + (high-low)/2
+	for _, part := range [][2]int{{low, middle}, {middle, high}} {
+		_, err := session.Call(operations[part[0]:part[1]]...)
+		if err == nil {
+			continue
+		}
+		if !isServerError(err) {
+			return nil
+		}
+		return session.isolateFailure(operations, part[0], part[1], err)
+	}
+	return nil
+}
+
+func (session *Session) newBatchError(operations []interface{}, index int, err error) *BatchError {
+	if batchError, ok := err.(*BatchError); ok {
+		err = batchError.Err
+	}
+	if index < 0 {
+		index = session.attributeServerError(operations, serverErrorMessage(err))
+	}
+	batchError := &BatchError{Index: index, Err: err}
+	if index >= 0 && index < len(operations) {
+		description := session.describeOperation(operations[index])
+		batchError.Operation = operations[index]
+		batchError.Action = description.Action
+		batchError.EntityType = description.EntityType
+		batchError.EntityKey = description.EntityKey
+	}
+	return batchError
+}
+
+func (session *Session) describeOperation(operation interface{}) operationDescription {
+	var description operationDescription
+	data, err := json.Marshal(operation)
+	if err != nil {
+		return description
+	}
+	if err := json.Unmarshal(data, &description); err != nil {
+		return description
+	}
+	if len(description.EntityKey) == 0 && description.EntityData != nil {
+		for _, pk := range session.GetPrimaryKeyAttributes(description.EntityType) {
+			if value, ok := description.EntityData[pk]; ok {
+				description.EntityKey = append(description.EntityKey, fmt.Sprintf("%v", value))
+			}
+		}
+	}
+	return description
+}
+
+// attributeServerError makes a best effort guess of which operation a server
+// error message refers to. It returns -1 when the message is ambiguous.
+func (session *Session) attributeServerError(operations []interface{}, message string) int {
+	if message == "" {
+		return -1
+	}
+	if match := operationIndexPattern.FindStringSubmatch(message); match != nil {
+		if index, err := strconv.Atoi(match[1]); err == nil && index < len(operations) {
+			return index
+		}
+	}
+	var descriptions []operationDescription
+	for _, operation := range operations {
+		descriptions = append(descriptions, session.describeOperation(operation))
+	}
+	matchOne := func(matches func(description operationDescription) bool) int {
+		index := -1
+		for i, description := range descriptions {
+			if !matches(description) {
+				continue
+			}
+			if index >= 0 {
+				return -1
+			}
+			index = i
+		}
+		return index
+	}
+	byKey := matchOne(func(description operationDescription) bool {
+		for _, key := range description.EntityKey {
+			if key != "" && strings.Contains(message, key) {
+				return true
+			}
+		}
+		return false
+	})
+	if byKey >= 0 {
+		return byKey
+	}
+	return matchOne(func(description operationDescription) bool {
+		if description.EntityType == "" {
+			return false
+		}
+		pattern := fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(description.EntityType))
+		matched, _ := regexp.MatchString(pattern, message)
+		return matched
+	})
+}
+
+func isServerError(err error) bool {
+	if batchError, ok := err.(*BatchError); ok {
+		err = batchError.Err
+	}
+	switch err.(type) {
+	case *ServerError, *ServerValidationError, *ServerPermissionDeniedError:
+		return true
+	}
+	return false
+}
+
+func serverErrorMessage(err error) string {
+	switch casted := err.(type) {
+	case *ServerError:
+		return casted.Msg
+	case *ServerValidationError:
+		return casted.Msg
+	case *ServerPermissionDeniedError:
+		return casted.Msg
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package ftrack
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func failingOperationResponse(fail func(operation map[string]interface{}) bool, content string) func(operations []map[string]interface{}) interface{} {
+	return func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if fail(op) {
+				return map[string]interface{}{
+					"exception":  "ValidationError",
+					"content":    content,
+					"error_code": 0,
+				}
+			}
+			results = append(results, map[string]interface{}{"action": op["action"], "data": true})
+		}
+		return results
+	}
+}
+
+func TestSession_CallBatchError(t *testing.T) {
+	session, _ := newMockSession(t, failingOperationResponse(
+		func(op map[string]interface{}) bool { return op["entity_type"] == "User" },
+		"Entity User with key abc-123 is not valid",
+	))
+	operations := []interface{}{
+		NewDeleteOperation("Task", []string{"def-456"}),
+		NewDeleteOperation("User", []string{"abc-123"}),
+	}
+	_, err := session.Call(operations...)
+	_, ok := err.(*ServerValidationError)
+	assert.True(t, ok, "Call should return the server error, got %T", err)
+
+	_, err = session.CallWithOptions(CallOptions{}, operations...)
+	var batchError *BatchError
+	if !errors.As(err, &batchError) {
+		t.Fatalf("Should return BatchError, got %T", err)
+	}
+	assert.Equal(t, 1, batchError.Index)
+	assert.Equal(t, "delete", batchError.Action)
+	assert.Equal(t, "User", batchError.EntityType)
+	assert.Equal(t, []string{"abc-123"}, batchError.EntityKey)
+	assert.False(t, batchError.Isolated)
+	var validationError *ServerValidationError
+	assert.True(t, errors.As(err, &validationError), "Should unwrap to the server error")
+
+	_, err = session.CallWithOptions(CallOptions{}, NewDeleteOperation("User", []string{"abc-123"}))
+	_, ok = err.(*ServerValidationError)
+	assert.True(t, ok, "Should not wrap single operation calls")
+}
+
+func TestSession_CallWithOptionsIsolateFailure(t *testing.T) {
+	session, server := newMockSession(t, failingOperationResponse(
+		func(op map[string]interface{}) bool {
+			key := op["entity_key"].([]interface{})
+			return key[0] == "3"
+		},
+		"Something went wrong",
+	))
+	var operations []interface{}
+	for _, key := range []string{"0", "1", "2", "3", "4"} {
+		operations = append(operations, NewDeleteOperation("Task", []string{key}))
+	}
+	_, err := session.CallWithOptions(CallOptions{}, operations...)
+	assert.Equal(t, -1, err.(*BatchError).Index, "Should not guess ambiguous errors")
+
+	_, err = session.CallWithOptions(CallOptions{IsolateFailure: true}, operations...)
+	batchError, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("Should return BatchError, got %T", err)
+	}
+	assert.Equal(t, 3, batchError.Index)
+	assert.Equal(t, []string{"3"}, batchError.EntityKey)
+	assert.True(t, batchError.Isolated)
+	assert.Equal(t, operations[3], batchError.Operation)
+	assert.Len(t, server.Batches(), 7)
+}
+
+func TestSession_CallWithOptionsConfirmsGuess(t *testing.T) {
+	session, _ := newMockSession(t, failingOperationResponse(
+		func(op map[string]interface{}) bool {
+			key := op["entity_key"].([]interface{})
+			return key[0] == "2"
+		},
+		"Operation 0 failed",
+	))
+	var operations []interface{}
+	for _, key := range []string{"0", "1", "2"} {
+		operations = append(operations, NewDeleteOperation("Task", []string{key}))
+	}
+	_, err := session.CallWithOptions(CallOptions{}, operations...)
+	batchError := err.(*BatchError)
+	assert.Equal(t, 0, batchError.Index, "Should guess from the message")
+	assert.False(t, batchError.Isolated)
+
+	_, err = session.CallWithOptions(CallOptions{IsolateFailure: true}, operations...)
+	batchError = err.(*BatchError)
+	assert.Equal(t, 2, batchError.Index, "Should not trust the guess")
+	assert.True(t, batchError.Isolated)
+}
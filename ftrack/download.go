@@ -0,0 +1,229 @@
+package ftrack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const partialDownloadSuffix string = ".part"
+
+type DownloadComponentOptions struct {
+	// OnProgress receives the cumulative download percentage.
+	OnProgress *func(int)
+	// OnTransferProgress receives cumulative bytes, total, rate and ETA.
+	OnTransferProgress *func(Progress)
+	// ProgressInterval throttles progress callbacks, defaults to
+	// DefaultProgressInterval. The final callback is never throttled.
+	ProgressInterval *time.Duration
+	// VerifySize compares the downloaded size with the FileComponent size,
+	// defaults to true.
+	VerifySize *bool
+	// Resume continues a partial download left next to the target path by an
+	// earlier call, defaults to true. Only used by DownloadComponentToFile.
+	Resume *bool
+	// Checksum is the expected hex encoded digest of the content.
+	Checksum     *string
+	ChecksumHash *func() hash.Hash
+}
+
+func (options *DownloadComponentOptions) setDefaults() {
+	if options.OnProgress == nil {
+		onProgress := func(int) {}
+		options.OnProgress = &onProgress
+	}
+	if options.VerifySize == nil {
+		verifySize := true
+		options.VerifySize = &verifySize
+	}
+	if options.Resume == nil {
+		resume := true
+		options.Resume = &resume
+	}
+	if options.ChecksumHash == nil {
+		checksumHash := sha256.New
+		options.ChecksumHash = &checksumHash
+	}
+}
+
+type DownloadError struct {
+	Msg         string
+	ComponentId uuid.UUID
+}
+
+func (error *DownloadError) Error() string {
+	return fmt.Sprintf("DownloadError: component %s: %s", error.ComponentId, error.Msg)
+}
+
+func (session *Session) getComponentSize(componentId uuid.UUID) (int64, error) {
+	result, err := session.Query(fmt.Sprintf("select size from FileComponent where id is %s", componentId))
+	if err != nil {
+		return 0, err
+	}
+	if len(result.Data) == 0 {
+		return 0, &DownloadError{Msg: "no FileComponent found", ComponentId: componentId}
+	}
+	if size, ok := toInt64(result.Data[0]["size"]); ok {
+		return size, nil
+	}
+	return 0, &DownloadError{Msg: fmt.Sprintf("invalid size %v", result.Data[0]["size"]), ComponentId: componentId}
+}
+
+func (session *Session) DownloadComponent(ctx context.Context, componentId uuid.UUID, writer io.Writer, options DownloadComponentOptions) (int64, error) {
+	options.setDefaults()
+	return session.downloadComponent(ctx, componentId, writer, 0, (*options.ChecksumHash)(), options)
+}
+
+func (session *Session) DownloadComponentToFile(ctx context.Context, componentId uuid.UUID, path string, options DownloadComponentOptions) (int64, error) {
+	options.setDefaults()
+	partialPath := path + partialDownloadSuffix
+	flags := os.O_CREATE | os.O_WRONLY
+	if !*options.Resume {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+	digest := (*options.ChecksumHash)()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		existing, err := os.Open(partialPath)
+		if err != nil {
+			return 0, err
+		}
+		_, err = io.Copy(digest, existing)
+		_ = existing.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	written, err := session.downloadComponent(ctx, componentId, file, offset, digest, options)
+	if err != nil {
+		if _, ok := err.(*DownloadError); ok {
+			_ = file.Close()
+			_ = os.Remove(partialPath)
+		}
+		return written, err
+	}
+	if err := file.Sync(); err != nil {
+		return written, err
+	}
+	if err := file.Close(); err != nil {
+		return written, err
+	}
+	return written, os.Rename(partialPath, path)
+}
+
+// downloadComponent writes the component content starting at offset to
+// writer. Writers that can be truncated are reset when the server ignores the
+// range request. The returned size includes offset.
+func (session *Session) downloadComponent(ctx context.Context, componentId uuid.UUID, writer io.Writer, offset int64, digest hash.Hash, options DownloadComponentOptions) (int64, error) {
+	var expectedSize int64 = -1
+	if *options.VerifySize {
+		size, err := session.getComponentSize(componentId)
+		if err != nil {
+			return 0, err
+		}
+		expectedSize = size
+		if offset > expectedSize {
+			return 0, &DownloadError{Msg: "partial download larger than component", ComponentId: componentId}
+		}
+	}
+	componentUrl, err := session.GetSignedComponentUrl(componentId, 0)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", componentUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	response, err := session.transferClient().Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		if offset > 0 {
+			truncater, ok := writer.(interface {
+				Truncate(int64) error
+				Seek(int64, int) (int64, error)
+			})
+			if !ok {
+				return 0, &DownloadError{Msg: "server does not support resuming downloads", ComponentId: componentId}
+			}
+			if err := truncater.Truncate(0); err != nil {
+				return 0, err
+			}
+			if _, err := truncater.Seek(0, io.SeekStart); err != nil {
+				return 0, err
+			}
+			offset = 0
+			digest.Reset()
+		}
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(response.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return 0, &DownloadError{Msg: "unexpected content range " + response.Header.Get("Content-Range"), ComponentId: componentId}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset == expectedSize {
+			return offset, session.verifyDownload(componentId, offset, expectedSize, digest, options)
+		}
+		fallthrough
+	default:
+		return 0, newResponseError(response)
+	}
+	total := expectedSize
+	if total < 0 && response.ContentLength >= 0 {
+		total = offset + response.ContentLength
+	}
+	written := offset
+	tracker := newProgressTracker(total, options.ProgressInterval, options.OnProgress, options.OnTransferProgress)
+	tracker.Add(offset)
+	reader := &progressReader{
+		Reader: response.Body,
+		Reporter: func(c int64) {
+			written += c
+			tracker.Add(c)
+		},
+	}
+	if _, err := io.Copy(io.MultiWriter(writer, digest), reader); err != nil {
+		return written, err
+	}
+	return written, session.verifyDownload(componentId, written, expectedSize, digest, options)
+}
+
+func (session *Session) verifyDownload(componentId uuid.UUID, size int64, expectedSize int64, digest hash.Hash, options DownloadComponentOptions) error {
+	if expectedSize >= 0 && size != expectedSize {
+		return &DownloadError{
+			Msg:         fmt.Sprintf("size mismatch expected %d got %d", expectedSize, size),
+			ComponentId: componentId,
+		}
+	}
+	if options.Checksum != nil {
+		checksum := hex.EncodeToString(digest.Sum(nil))
+		if !strings.EqualFold(checksum, *options.Checksum) {
+			return &DownloadError{
+				Msg:         fmt.Sprintf("checksum mismatch expected %s got %s", *options.Checksum, checksum),
+				ComponentId: componentId,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package ftrack
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newDownloadSession(t *testing.T, content []byte) (*Session, uuid.UUID) {
+	componentId := uuid.Must(uuid.NewV4(), nil)
+	var server *mockServer
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		if operations[0]["action"] == "get_signed_url" {
+			return []interface{}{map[string]interface{}{
+				"signed_url": fmt.Sprintf("%s/component/get?id=%s", server.URL, operations[0]["component_id"]),
+			}}
+		}
+		return []interface{}{
+			map[string]interface{}{
+				"action": "query",
+				"data": []interface{}{map[string]interface{}{
+					EntityTypeKey: "FileComponent",
+					"id":          componentId.String(),
+					"size":        len(content),
+				}},
+			},
+		}
+	})
+	server.files[componentId.String()] = content
+	return session, componentId
+}
+
+func TestSession_DownloadComponent(t *testing.T) {
+	content := []byte("downloaded component content")
+	session, componentId := newDownloadSession(t, content)
+	var progress []int
+	onProgress := func(p int) { progress = append(progress, p) }
+	digest := sha256.Sum256(content)
+	checksum := hex.EncodeToString(digest[:])
+	var buffer bytes.Buffer
+	written, err := session.DownloadComponent(context.Background(), componentId, &buffer, DownloadComponentOptions{
+		OnProgress: &onProgress,
+		Checksum:   &checksum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(len(content)), written)
+	assert.Equal(t, content, buffer.Bytes())
+	assert.Equal(t, 100, progress[len(progress)-1])
+
+	invalid := "00"
+	_, err = session.DownloadComponent(context.Background(), componentId, &bytes.Buffer{}, DownloadComponentOptions{Checksum: &invalid})
+	_, ok := err.(*DownloadError)
+	assert.True(t, ok, "Should reject checksum mismatch")
+}
+
+func TestSession_DownloadComponentToFileResume(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	session, componentId := newDownloadSession(t, content)
+	dir, err := ioutil.TempDir("", "ftrack-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "component.bin")
+	if err := ioutil.WriteFile(path+partialDownloadSuffix, content[:8], 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(content)
+	checksum := hex.EncodeToString(digest[:])
+	written, err := session.DownloadComponentToFile(context.Background(), componentId, path, DownloadComponentOptions{Checksum: &checksum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(len(content)), written)
+	downloaded, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, downloaded)
+	_, err = os.Stat(path + partialDownloadSuffix)
+	assert.True(t, os.IsNotExist(err), "Should rename partial download")
+}
+
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.mu.Lock()
+	transport.requests = append(transport.requests, request.Method+" "+request.URL.Path)
+	transport.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestSession_HttpClientTransfers(t *testing.T) {
+	var server *mockServer
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		if op["action"] == "get_signed_url" {
+			return map[string]interface{}{
+				"signed_url": fmt.Sprintf("%s/component/get?id=%s", server.URL, op["component_id"]),
+			}
+		}
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	transport := &recordingTransport{}
+	session.HttpClient = &http.Client{Transport: transport}
+
+	content := []byte("transferred")
+	create, err := session.CreateComponentFromReader(context.Background(), bytes.NewReader(content), int64(len(content)), CreateComponentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	componentId := uuid.FromStringOrNil(create[0].Data["id"].(string))
+	var buffer bytes.Buffer
+	verifySize := false
+	if _, err := session.DownloadComponent(context.Background(), componentId, &buffer, DownloadComponentOptions{VerifySize: &verifySize}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, buffer.Bytes())
+	exists, err := (&ServerAccessor{Session: session}).Exists(context.Background(), componentId.String())
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	var transfers []string
+	for _, request := range transport.requests {
+		if request != "POST "+DefaultApiEndpoint {
+			transfers = append(transfers, request)
+		}
+	}
+	assert.Equal(t, []string{"PUT /upload/" + componentId.String(), "GET /component/get", "HEAD /component/get"}, transfers)
+}
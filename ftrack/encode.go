@@ -0,0 +1,67 @@
+package ftrack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+type EncodedMediaOutput struct {
+	ComponentId string `json:"component_id"`
+	Format      string `json:"format"`
+}
+
+type encodeMediaJobData struct {
+	SourceComponentId string               `json:"source_component_id"`
+	Output            []EncodedMediaOutput `json:"output"`
+}
+
+// EncodeMedia starts encoding the component into web playable reviewables
+// and returns the Job tracking it. When versionId is set the reviewables are
+// added to that AssetVersion.
+func (session *Session) EncodeMedia(componentId uuid.UUID, versionId string, keepOriginal KeepOriginal) (*Job, error) {
+	result, err := session.Call(NewEncodeMediaOperation(componentId, versionId, keepOriginal))
+	if err != nil {
+		return nil, err
+	}
+	return session.GetJob(result[0].(EncodeMediaResult).JobId)
+}
+
+// EncodeMediaFromFile uploads the file at path to the server location and
+// encodes it like EncodeMedia.
+func (session *Session) EncodeMediaFromFile(path string, versionId string, keepOriginal KeepOriginal) (*Job, error) {
+	create, err := session.CreateComponent(path, CreateComponentOptions{})
+	if err != nil {
+		return nil, err
+	}
+	componentId, err := uuid.FromString(fmt.Sprint(create[0].Data["id"]))
+	if err != nil {
+		return nil, err
+	}
+	return session.EncodeMedia(componentId, versionId, keepOriginal)
+}
+
+// WaitForEncodedMedia waits for the encode_media Job to finish and returns
+// the FileComponents it created.
+func (session *Session) WaitForEncodedMedia(ctx context.Context, jobId string, pollInterval time.Duration) ([]map[string]interface{}, error) {
+	job, err := session.WaitForJob(ctx, jobId, pollInterval)
+	if err != nil {
+		return nil, err
+	}
+	var data encodeMediaJobData
+	if job.RawData != "" {
+		if err := json.Unmarshal([]byte(job.RawData), &data); err != nil {
+			return nil, err
+		}
+	}
+	var ids []string
+	for _, output := range data.Output {
+		ids = append(ids, output.ComponentId)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return session.queryInBatches("select id, name, file_type, size, version_id from FileComponent where id in (%s)", ids)
+}
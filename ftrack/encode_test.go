@@ -0,0 +1,70 @@
+package ftrack
+
+import (
+	"context"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSession_EncodeMedia(t *testing.T) {
+	var polls int32
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		op := operations[0]
+		switch {
+		case op["action"] == "encode_media":
+			return []interface{}{map[string]interface{}{"job_id": "job-1"}}
+		case strings.Contains(op["expression"].(string), "from Job"):
+			status := "running"
+			if atomic.AddInt32(&polls, 1) > 2 {
+				status = "done"
+			}
+			return []interface{}{map[string]interface{}{"action": "query", "data": []interface{}{map[string]interface{}{
+				EntityTypeKey: "Job",
+				"id":          "job-1",
+				"status":      status,
+				"data":        `{"source_component_id": "source", "output": [{"component_id": "mp4", "format": "mp4"}, {"component_id": "webm", "format": "webm"}]}`,
+			}}}}
+		default:
+			return []interface{}{map[string]interface{}{"action": "query", "data": []interface{}{
+				map[string]interface{}{EntityTypeKey: "FileComponent", "id": "mp4", "file_type": ".mp4"},
+				map[string]interface{}{EntityTypeKey: "FileComponent", "id": "webm", "file_type": ".webm"},
+			}}}
+		}
+	})
+	componentId := uuid.Must(uuid.NewV4(), nil)
+	job, err := session.EncodeMedia(componentId, "version-1", KeepOriginalFalse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "job-1", job.Id)
+	assert.Equal(t, JobStatusRunning, job.Status)
+	encode := server.Batches()[0][0]
+	assert.Equal(t, componentId.String(), encode["component_id"])
+	assert.Equal(t, "version-1", encode["version_id"])
+	assert.Equal(t, false, encode["keep_original"])
+
+	components, err := session.WaitForEncodedMedia(context.Background(), "job-1", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, components, 2)
+	assert.Equal(t, ".mp4", components[0]["file_type"])
+	query := server.Batches()[len(server.Batches())-1][0]["expression"].(string)
+	assert.Contains(t, query, `"mp4", "webm"`)
+}
+
+func TestKeepOriginal_MarshalJSON(t *testing.T) {
+	for keepOriginal, expected := range map[KeepOriginal]string{
+		KeepOriginalAuto:  `"auto"`,
+		KeepOriginalTrue:  "true",
+		KeepOriginalFalse: "false",
+	} {
+		encoded, err := keepOriginal.MarshalJSON()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(encoded))
+	}
+}
@@ -49,3 +49,30 @@ type MalformedResponseError struct {
 func (error *MalformedResponseError) Error() string {
 	return fmt.Sprintf("MalformedResponseError: content: %s", error.Content)
 }
+
+// BatchError is returned by CallWithOptions for a failed batch. Index is -1
+// when the failing operation is unknown, Isolated reports that it was
+// confirmed by running the batch in parts rather than guessed from Err.
+type BatchError struct {
+	Index      int
+	Operation  interface{}
+	Action     string
+	EntityType string
+	EntityKey  []string
+	Isolated   bool
+	Err        error
+}
+
+func (error *BatchError) Error() string {
+	if error.Index < 0 {
+		return fmt.Sprintf("BatchError: unknown operation failed: %s", error.Err)
+	}
+	return fmt.Sprintf(
+		"BatchError: operation %d (%s %s %v) failed: %s",
+		error.Index, error.Action, error.EntityType, error.EntityKey, error.Err,
+	)
+}
+
+func (error *BatchError) Unwrap() error {
+	return error.Err
+}
@@ -0,0 +1,267 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	bridgePublish     = "publish"
+	bridgeSubscribe   = "subscribe"
+	bridgeUnsubscribe = "unsubscribe"
+	bridgeEvent       = "event"
+	bridgeAck         = "ack"
+)
+
+const DefaultBridgeTimeout = 10 * time.Second
+
+// bridgeMessage is exchanged as newline delimited JSON between a Bridge and
+// the hubs connected to it.
+type bridgeMessage struct {
+	Type         string `json:"type"`
+	Id           string `json:"id,omitempty"`
+	Subscription string `json:"subscription,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Event        *Event `json:"event,omitempty"`
+}
+
+type bridgeConn struct {
+	conn    net.Conn
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newBridgeConn(conn net.Conn) *bridgeConn {
+	return &bridgeConn{conn: conn, encoder: json.NewEncoder(conn)}
+}
+
+func (conn *bridgeConn) send(message bridgeMessage) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.encoder.Encode(message)
+}
+
+// Bridge shares a LocalHub with hubs in other processes connected with
+// DialBridge, letting services on one machine exchange events without a
+// server.
+type Bridge struct {
+	hub       *LocalHub
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[*bridgeConn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewBridge(hub *LocalHub) *Bridge {
+	return &Bridge{hub: hub, conns: map[*bridgeConn]bool{}}
+}
+
+// Serve accepts connections on listener until the bridge is closed.
+func (bridge *Bridge) Serve(listener net.Listener) error {
+	bridge.mu.Lock()
+	if bridge.closed {
+		bridge.mu.Unlock()
+		return ErrClosed
+	}
+	bridge.listeners = append(bridge.listeners, listener)
+	bridge.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			bridge.mu.Lock()
+			closed := bridge.closed
+			bridge.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		client := newBridgeConn(conn)
+		bridge.mu.Lock()
+		if bridge.closed {
+			bridge.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		bridge.conns[client] = true
+		bridge.wg.Add(1)
+		bridge.mu.Unlock()
+		go bridge.serveConn(client)
+	}
+}
+
+func (bridge *Bridge) serveConn(client *bridgeConn) {
+	defer bridge.wg.Done()
+	var subscribers []string
+	defer func() {
+		for _, subscriberId := range subscribers {
+			_ = bridge.hub.Unsubscribe(subscriberId)
+		}
+		_ = client.conn.Close()
+		bridge.mu.Lock()
+		delete(bridge.conns, client)
+		bridge.mu.Unlock()
+	}()
+	decoder := json.NewDecoder(client.conn)
+	for {
+		var message bridgeMessage
+		if err := decoder.Decode(&message); err != nil {
+			return
+		}
+		var err error
+		switch message.Type {
+		case bridgePublish:
+			if message.Event == nil {
+				err = errors.New("publish without event")
+				break
+			}
+			_, err = bridge.hub.Publish(message.Event)
+		case bridgeSubscribe:
+			var expression Expression
+			if expression, err = ParseExpression(message.Subscription); err != nil {
+				break
+			}
+			// Events are tagged with the subscriber they are sent for, the
+			// hub delivers each copy to that subscriber only.
+			subscriberId := message.Id
+			handler := func(event *Event) map[string]interface{} {
+				_ = client.send(bridgeMessage{Type: bridgeEvent, Id: subscriberId, Event: event})
+				return nil
+			}
+			err = bridge.hub.addSubscriber(newSubscriber(message.Id, message.Subscription, expression, handler, *bridge.hub.options.ApplicationId))
+			if err == nil {
+				subscribers = append(subscribers, message.Id)
+			}
+		case bridgeUnsubscribe:
+			err = bridge.hub.Unsubscribe(message.Id)
+			for i, subscriberId := range subscribers {
+				if subscriberId == message.Id {
+					subscribers = append(subscribers[:i], subscribers[i+1:]...)
+					break
+				}
+			}
+		default:
+			err = errors.New(fmt.Sprintf("unknown bridge message type %s", message.Type))
+		}
+		if message.Type == bridgeSubscribe || message.Type == bridgeUnsubscribe {
+			ack := bridgeMessage{Type: bridgeAck, Id: message.Id}
+			if err != nil {
+				ack.Error = err.Error()
+			}
+			if sendErr := client.send(ack); sendErr != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close stops accepting connections, disconnects all hubs and waits for
+// their connections to finish.
+func (bridge *Bridge) Close() error {
+	bridge.mu.Lock()
+	bridge.closed = true
+	for _, listener := range bridge.listeners {
+		_ = listener.Close()
+	}
+	for client := range bridge.conns {
+		_ = client.conn.Close()
+	}
+	bridge.mu.Unlock()
+	bridge.wg.Wait()
+	return nil
+}
+
+// DialBridge connects to a Bridge and returns a hub publishing and
+// subscribing through it. Subscribe returns once the bridge confirmed the
+// subscription.
+func DialBridge(network string, address string, options LocalHubOptions) (*LocalHub, error) {
+	conn, err := net.DialTimeout(network, address, DefaultBridgeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	hub := NewLocalHub(options)
+	client := newBridgeConn(conn)
+	var mu sync.Mutex
+	acks := map[string]chan error{}
+	request := func(message bridgeMessage) error {
+		ack := make(chan error, 1)
+		mu.Lock()
+		acks[message.Id] = ack
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(acks, message.Id)
+			mu.Unlock()
+		}()
+		if err := client.send(message); err != nil {
+			return err
+		}
+		select {
+		case err := <-ack:
+			return err
+		case <-time.After(DefaultBridgeTimeout):
+			return errors.New(fmt.Sprintf("bridge did not acknowledge %s of %s", message.Type, message.Id))
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		decoder := json.NewDecoder(conn)
+		for {
+			var message bridgeMessage
+			if err := decoder.Decode(&message); err != nil {
+				mu.Lock()
+				for _, ack := range acks {
+					select {
+					case ack <- ErrClosed:
+					default:
+					}
+				}
+				mu.Unlock()
+				return
+			}
+			switch message.Type {
+			case bridgeEvent:
+				if message.Event != nil {
+					_ = hub.receiveFor(message.Event, message.Id)
+				}
+			case bridgeAck:
+				var err error
+				if message.Error != "" {
+					err = errors.New(message.Error)
+				}
+				mu.Lock()
+				if ack, ok := acks[message.Id]; ok {
+					ack <- err
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	hub.forward = func(event *Event) error {
+		return client.send(bridgeMessage{Type: bridgePublish, Event: event})
+	}
+	hub.onSubscribe = func(subscriber *subscriber) error {
+		return request(bridgeMessage{Type: bridgeSubscribe, Id: subscriber.id, Subscription: subscriber.subscription})
+	}
+	hub.onUnsubscribe = func(subscriber *subscriber) error {
+		return request(bridgeMessage{Type: bridgeUnsubscribe, Id: subscriber.id})
+	}
+	hub.onClose = func() error {
+		err := conn.Close()
+		<-done
+		return err
+	}
+	// Replies are targeted at the hub id, subscribe to them with it.
+	if err := request(bridgeMessage{Type: bridgeSubscribe, Id: hub.Id, Subscription: "topic=" + TopicReply}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return hub, nil
+}
@@ -0,0 +1,116 @@
+package event
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBridge(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := NewBridge(NewLocalHub(LocalHubOptions{}))
+	go func() { _ = bridge.Serve(listener) }()
+	defer bridge.Close()
+
+	publisher, err := DialBridge("tcp", listener.Addr().String(), LocalHubOptions{Username: stringPointer("publisher")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	consumer, err := DialBridge("tcp", listener.Addr().String(), LocalHubOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	received := make(chan *Event, 100)
+	_, err = consumer.Subscribe("topic=ftrack.test.*", func(event *Event) map[string]interface{} {
+		received <- event
+		if event.Topic == "ftrack.test.request" {
+			return map[string]interface{}{"answer": event.Data["index"]}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = consumer.Subscribe("topic=(", func(event *Event) map[string]interface{} { return nil })
+	assert.NotNil(t, err)
+
+	for i := 0; i < 50; i++ {
+		if _, err := publisher.Publish(NewEvent("ftrack.test.update", map[string]interface{}{"index": float64(i)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		select {
+		case event := <-received:
+			assert.Equal(t, float64(i), event.Data["index"])
+			assert.Equal(t, "publisher", event.Source.User.Username)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := publisher.PublishAndWait(ctx, NewEvent("ftrack.test.request", map[string]interface{}{"index": 7.0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 7.0, reply.Data["answer"])
+}
+
+func TestBridge_SubscribersReceiveOnce(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := NewBridge(NewLocalHub(LocalHubOptions{}))
+	go func() { _ = bridge.Serve(listener) }()
+	defer bridge.Close()
+
+	hub, err := DialBridge("tcp", listener.Addr().String(), LocalHubOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	calls := map[string]int{}
+	handler := func(name string) Handler {
+		return func(event *Event) map[string]interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[name]++
+			return nil
+		}
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, err := hub.Subscribe("topic=ftrack.test", handler(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := hub.Publish(NewEvent("ftrack.test", nil)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		delivered := calls["first"] > 0 && calls["second"] > 0
+		mu.Unlock()
+		if delivered || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, hub.Close())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"first": 1, "second": 1}, calls)
+}
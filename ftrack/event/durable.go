@@ -0,0 +1,378 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDurableRetryDelay    = time.Second
+	DefaultDurableMaxRetryDelay = time.Minute
+	DefaultDedupeWindow         = 10000
+)
+
+// compactThreshold is the number of acknowledgements after which the log is
+// rewritten without the acknowledged events.
+const compactThreshold = 1000
+
+const (
+	walEvent = "event"
+	walAck   = "ack"
+)
+
+type walRecord struct {
+	Type  string `json:"type"`
+	Id    string `json:"id,omitempty"`
+	Event *Event `json:"event,omitempty"`
+}
+
+// DurableHandler processes an event, returning an error retries it later.
+type DurableHandler func(event *Event) error
+
+type DurableConsumerOptions struct {
+	// RetryDelay is the delay before retrying a failed event, it doubles with
+	// every failure up to MaxRetryDelay.
+	RetryDelay    *time.Duration
+	MaxRetryDelay *time.Duration
+	// DedupeWindow is the number of processed event ids remembered to drop
+	// redelivered events.
+	DedupeWindow *int
+	// MaxAttempts is the number of times an event is handled before it is
+	// given up on, zero retries it until it succeeds.
+	MaxAttempts *int
+	OnError     *func(event *Event, err error)
+	// OnDeadLetter is called with the last error of an event given up on
+	// after MaxAttempts, the event is then acknowledged.
+	OnDeadLetter *func(event *Event, err error)
+}
+
+func (options *DurableConsumerOptions) setDefaults() {
+	if options.RetryDelay == nil {
+		delay := DefaultDurableRetryDelay
+		options.RetryDelay = &delay
+	}
+	if options.MaxRetryDelay == nil {
+		delay := DefaultDurableMaxRetryDelay
+		options.MaxRetryDelay = &delay
+	}
+	if options.DedupeWindow == nil {
+		window := DefaultDedupeWindow
+		options.DedupeWindow = &window
+	}
+	if options.MaxAttempts == nil {
+		attempts := 0
+		options.MaxAttempts = &attempts
+	}
+}
+
+// DurableConsumer gives event handlers at-least-once semantics. Received
+// events are appended to a write-ahead log before they are acknowledged to
+// the hub and are removed from it once the handler succeeded, events left in
+// the log are processed again when the consumer is reopened.
+type DurableConsumer struct {
+	path    string
+	handler DurableHandler
+	options DurableConsumerOptions
+	mu      sync.Mutex
+	file    *os.File
+	pending []*Event
+	// seen holds the ids of pending and processed events, processed in the
+	// order they were acknowledged.
+	seen      map[string]bool
+	processed []string
+	acks      int
+	// attempt is the number of the attempt at handling the event at the head
+	// of the queue.
+	attempt int
+	notify  chan struct{}
+}
+
+func NewDurableConsumer(path string, handler DurableHandler, options DurableConsumerOptions) (*DurableConsumer, error) {
+	options.setDefaults()
+	consumer := &DurableConsumer{
+		path:    path,
+		handler: handler,
+		options: options,
+		seen:    map[string]bool{},
+		notify:  make(chan struct{}, 1),
+	}
+	if err := consumer.load(); err != nil {
+		return nil, err
+	}
+	if err := consumer.compact(); err != nil {
+		return nil, err
+	}
+	if len(consumer.pending) > 0 {
+		consumer.signal()
+	}
+	return consumer, nil
+}
+
+// load replays the log. A truncated last record left by a crash is ignored.
+func (consumer *DurableConsumer) load() error {
+	file, err := os.Open(consumer.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	events := map[string]*Event{}
+	var order []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), int(maxMessageSize))
+	for scanner.Scan() {
+		var record walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		switch record.Type {
+		case walEvent:
+			if record.Event == nil || consumer.seen[record.Event.Id] {
+				continue
+			}
+			consumer.seen[record.Event.Id] = true
+			events[record.Event.Id] = record.Event
+			order = append(order, record.Event.Id)
+		case walAck:
+			delete(events, record.Id)
+			consumer.seen[record.Id] = true
+			consumer.processed = append(consumer.processed, record.Id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, id := range order {
+		if event, ok := events[id]; ok {
+			consumer.pending = append(consumer.pending, event)
+		}
+	}
+	consumer.trim()
+	return nil
+}
+
+// trim forgets processed ids beyond the dedupe window.
+func (consumer *DurableConsumer) trim() {
+	excess := len(consumer.processed) - *consumer.options.DedupeWindow
+	if excess <= 0 {
+		return
+	}
+	for _, id := range consumer.processed[:excess] {
+		delete(consumer.seen, id)
+	}
+	consumer.processed = append([]string(nil), consumer.processed[excess:]...)
+}
+
+// compact atomically rewrites the log with the pending events and the
+// processed ids, then reopens it for appending.
+func (consumer *DurableConsumer) compact() error {
+	if consumer.file != nil {
+		_ = consumer.file.Close()
+		consumer.file = nil
+	}
+	temporary, err := os.Create(consumer.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(temporary)
+	encoder := json.NewEncoder(writer)
+	for _, id := range consumer.processed {
+		if err = encoder.Encode(walRecord{Type: walAck, Id: id}); err != nil {
+			break
+		}
+	}
+	for _, event := range consumer.pending {
+		if err != nil {
+			break
+		}
+		err = encoder.Encode(walRecord{Type: walEvent, Event: event})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = temporary.Sync()
+	}
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporary.Name(), consumer.path)
+	}
+	if err != nil {
+		_ = os.Remove(temporary.Name())
+		return err
+	}
+	if directory, err := os.Open(filepath.Dir(consumer.path)); err == nil {
+		_ = directory.Sync()
+		_ = directory.Close()
+	}
+	consumer.acks = 0
+	consumer.file, err = os.OpenFile(consumer.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (consumer *DurableConsumer) append(record walRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := consumer.file.Write(append(encoded, '\n')); err != nil {
+		return err
+	}
+	return consumer.file.Sync()
+}
+
+func (consumer *DurableConsumer) signal() {
+	select {
+	case consumer.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Add persists event for processing. Events already pending or processed are
+// dropped.
+func (consumer *DurableConsumer) Add(event *Event) error {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+	if consumer.file == nil {
+		return ErrClosed
+	}
+	if consumer.seen[event.Id] {
+		return nil
+	}
+	if err := consumer.append(walRecord{Type: walEvent, Event: event}); err != nil {
+		return err
+	}
+	consumer.seen[event.Id] = true
+	consumer.pending = append(consumer.pending, event)
+	consumer.signal()
+	return nil
+}
+
+// Handler returns a hub handler adding the received events to the consumer.
+func (consumer *DurableConsumer) Handler() Handler {
+	return func(event *Event) map[string]interface{} {
+		if err := consumer.Add(event); err != nil && consumer.options.OnError != nil {
+			(*consumer.options.OnError)(event, err)
+		}
+		return nil
+	}
+}
+
+func (consumer *DurableConsumer) Subscribe(hub Hub, subscription string) (string, error) {
+	return hub.Subscribe(subscription, consumer.Handler())
+}
+
+// Pending returns the number of events not processed yet.
+func (consumer *DurableConsumer) Pending() int {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+	return len(consumer.pending)
+}
+
+func (consumer *DurableConsumer) ack(event *Event) error {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+	if consumer.file == nil {
+		return ErrClosed
+	}
+	if err := consumer.append(walRecord{Type: walAck, Id: event.Id}); err != nil {
+		return err
+	}
+	consumer.pending = consumer.pending[1:]
+	consumer.processed = append(consumer.processed, event.Id)
+	consumer.trim()
+	consumer.acks++
+	if consumer.acks >= compactThreshold {
+		return consumer.compact()
+	}
+	return nil
+}
+
+// Attempt returns the number of the attempt at handling the current event,
+// starting at 1, for handlers to report it.
+func (consumer *DurableConsumer) Attempt() int {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+	return consumer.attempt
+}
+
+// Run processes the pending events in order until ctx is done. Failed events
+// are retried before any later event is processed, up to MaxAttempts times.
+// Attempts are counted from the start of Run, a failure once ctx is done is
+// not counted and leaves the event pending.
+func (consumer *DurableConsumer) Run(ctx context.Context) error {
+	delay := *consumer.options.RetryDelay
+	attempts := 0
+	for {
+		consumer.mu.Lock()
+		var event *Event
+		if len(consumer.pending) > 0 {
+			event = consumer.pending[0]
+			consumer.attempt = attempts + 1
+		}
+		consumer.mu.Unlock()
+		if event == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-consumer.notify:
+			}
+			continue
+		}
+		if err := consumer.handler(event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if consumer.options.OnError != nil {
+				(*consumer.options.OnError)(event, err)
+			}
+			attempts++
+			if maxAttempts := *consumer.options.MaxAttempts; maxAttempts > 0 && attempts >= maxAttempts {
+				if consumer.options.OnDeadLetter != nil {
+					(*consumer.options.OnDeadLetter)(event, err)
+				}
+				attempts = 0
+				delay = *consumer.options.RetryDelay
+				if err := consumer.ack(event); err != nil {
+					return err
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > *consumer.options.MaxRetryDelay {
+				delay = *consumer.options.MaxRetryDelay
+			}
+			continue
+		}
+		attempts = 0
+		delay = *consumer.options.RetryDelay
+		if err := consumer.ack(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (consumer *DurableConsumer) Close() error {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+	if consumer.file == nil {
+		return nil
+	}
+	err := consumer.file.Close()
+	consumer.file = nil
+	return err
+}
@@ -0,0 +1,223 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHandler struct {
+	mu       sync.Mutex
+	handled  []string
+	failures map[string]int
+	done     chan string
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{failures: map[string]int{}, done: make(chan string, 100)}
+}
+
+func (handler *recordingHandler) handle(event *Event) error {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if handler.failures[event.Id] > 0 {
+		handler.failures[event.Id]--
+		return errors.New("temporary failure")
+	}
+	handler.handled = append(handler.handled, event.Id)
+	handler.done <- event.Id
+	return nil
+}
+
+func (handler *recordingHandler) wait(t *testing.T, id string) {
+	select {
+	case handled := <-handler.done:
+		assert.Equal(t, id, handled)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", id)
+	}
+}
+
+func newEventWithId(id string) *Event {
+	event := NewEvent("ftrack.update", nil)
+	event.Id = id
+	return event
+}
+
+func TestDurableConsumer_RetriesAndDedupes(t *testing.T) {
+	path := filepath.Join(tempDir(t), "events.wal")
+	handler := newRecordingHandler()
+	handler.failures["a"] = 2
+	delay := time.Millisecond
+	var errs []error
+	onError := func(event *Event, err error) {
+		errs = append(errs, err)
+	}
+	consumer, err := NewDurableConsumer(path, handler.handle, DurableConsumerOptions{RetryDelay: &delay, OnError: &onError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	hub := NewLocalHub(LocalHubOptions{})
+	defer hub.Close()
+	_, err = consumer.Subscribe(hub, "topic=ftrack.update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = consumer.Run(ctx) }()
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		_, _ = hub.Publish(newEventWithId(id))
+	}
+	handler.wait(t, "a")
+	handler.wait(t, "b")
+	handler.wait(t, "c")
+	_, _ = hub.Publish(newEventWithId("b"))
+	hub.Wait()
+	assert.Equal(t, []string{"a", "b", "c"}, handler.handled)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, 0, consumer.Pending())
+}
+
+func TestDurableConsumer_ReplaysAfterRestart(t *testing.T) {
+	path := filepath.Join(tempDir(t), "events.wal")
+	handler := newRecordingHandler()
+	consumer, err := NewDurableConsumer(path, handler.handle, DurableConsumerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		assert.Nil(t, consumer.Add(newEventWithId(id)))
+	}
+	// Process the first event only, then stop as if the process crashed.
+	handler.failures["b"] = 1000
+	longDelay := time.Hour
+	consumer.options.RetryDelay = &longDelay
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error)
+	go func() { finished <- consumer.Run(ctx) }()
+	handler.wait(t, "a")
+	cancel()
+	<-finished
+	assert.Nil(t, consumer.Close())
+
+	// A truncated record from the crash is ignored.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString(`{"type":"event","event":{"id":"d"`)
+	_ = file.Close()
+
+	restarted := newRecordingHandler()
+	consumer, err = NewDurableConsumer(path, restarted.handle, DurableConsumerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	assert.Equal(t, 2, consumer.Pending())
+	assert.Nil(t, consumer.Add(newEventWithId("a")))
+	assert.Equal(t, 2, consumer.Pending())
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, strings.Count(string(content), "\n"))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = consumer.Run(ctx) }()
+	restarted.wait(t, "b")
+	restarted.wait(t, "c")
+}
+
+func TestDurableConsumer_DedupeWindow(t *testing.T) {
+	path := filepath.Join(tempDir(t), "events.wal")
+	handler := newRecordingHandler()
+	window := 2
+	consumer, err := NewDurableConsumer(path, handler.handle, DurableConsumerOptions{DedupeWindow: &window})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = consumer.Run(ctx) }()
+	for _, id := range []string{"a", "b", "c"} {
+		assert.Nil(t, consumer.Add(newEventWithId(id)))
+		handler.wait(t, id)
+	}
+	assert.Nil(t, consumer.Add(newEventWithId("c")))
+	assert.Nil(t, consumer.Add(newEventWithId("a")))
+	handler.wait(t, "a")
+}
+
+func TestDurableConsumer_DeadLetter(t *testing.T) {
+	path := filepath.Join(tempDir(t), "events.wal")
+	handler := newRecordingHandler()
+	handler.failures["a"] = 1000
+	delay := time.Millisecond
+	maxAttempts := 3
+	var mu sync.Mutex
+	attempts := 0
+	onError := func(event *Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+	}
+	deadLetters := make(chan string, 10)
+	onDeadLetter := func(event *Event, err error) {
+		deadLetters <- event.Id
+	}
+	consumer, err := NewDurableConsumer(path, handler.handle, DurableConsumerOptions{
+		RetryDelay:   &delay,
+		MaxAttempts:  &maxAttempts,
+		OnError:      &onError,
+		OnDeadLetter: &onDeadLetter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		assert.Nil(t, consumer.Add(newEventWithId(id)))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error)
+	go func() { finished <- consumer.Run(ctx) }()
+	handler.wait(t, "b")
+	cancel()
+	<-finished
+	assert.Equal(t, "a", <-deadLetters)
+	assert.Len(t, deadLetters, 0)
+	mu.Lock()
+	assert.Equal(t, 3, attempts)
+	mu.Unlock()
+	assert.Equal(t, 0, consumer.Pending())
+	assert.Nil(t, consumer.Close())
+
+	consumer, err = NewDurableConsumer(path, handler.handle, DurableConsumerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	assert.Equal(t, 0, consumer.Pending())
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ftrack-durable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
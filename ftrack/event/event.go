@@ -0,0 +1,50 @@
+package event
+
+import (
+	"encoding/json"
+	uuid "github.com/satori/go.uuid"
+)
+
+type User struct {
+	Id       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type Source struct {
+	Id            string `json:"id,omitempty"`
+	ApplicationId string `json:"applicationId,omitempty"`
+	User          *User  `json:"user,omitempty"`
+}
+
+type Event struct {
+	Id             string                 `json:"id"`
+	Topic          string                 `json:"topic"`
+	Data           map[string]interface{} `json:"data"`
+	Target         string                 `json:"target"`
+	Source         *Source                `json:"source,omitempty"`
+	InReplyToEvent string                 `json:"inReplyToEvent,omitempty"`
+	Sent           interface{}            `json:"sent,omitempty"`
+}
+
+func NewEvent(topic string, data map[string]interface{}) *Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return &Event{
+		Id:    uuid.Must(uuid.NewV4(), nil).String(),
+		Topic: topic,
+		Data:  data,
+	}
+}
+
+// Payload returns the event decoded from JSON, the form subscription
+// expressions are matched against.
+func (event *Event) Payload() map[string]interface{} {
+	var payload map[string]interface{}
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	_ = json.Unmarshal(encoded, &payload)
+	return payload
+}
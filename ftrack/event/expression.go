@@ -0,0 +1,365 @@
+package event
+
+import (
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/internal/syntax"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expression is a compiled subscription expression such as
+// `topic=ftrack.update and source.user.username="john"`.
+type Expression interface {
+	// Match reports whether the decoded event payload satisfies the
+	// expression.
+	Match(payload map[string]interface{}) bool
+	String() string
+}
+
+type And struct {
+	Expressions []Expression
+}
+
+func (and *And) Match(payload map[string]interface{}) bool {
+	for _, expression := range and.Expressions {
+		if !expression.Match(payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func (and *And) String() string {
+	return joinExpressions(and.Expressions, " and ")
+}
+
+type Or struct {
+	Expressions []Expression
+}
+
+func (or *Or) Match(payload map[string]interface{}) bool {
+	for _, expression := range or.Expressions {
+		if expression.Match(payload) {
+			return true
+		}
+	}
+	return false
+}
+
+func (or *Or) String() string {
+	return joinExpressions(or.Expressions, " or ")
+}
+
+type Not struct {
+	Expression Expression
+}
+
+func (not *Not) Match(payload map[string]interface{}) bool {
+	return !not.Expression.Match(payload)
+}
+
+func (not *Not) String() string {
+	return fmt.Sprintf("not %s", group(not.Expression))
+}
+
+// Any matches when Expression matches at least one element of the list at
+// Path, paths in Expression are relative to the element.
+type Any struct {
+	Path       string
+	Expression Expression
+}
+
+func (anyExpression *Any) Match(payload map[string]interface{}) bool {
+	value, ok := lookup(payload, anyExpression.Path)
+	if !ok {
+		return false
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if element, ok := item.(map[string]interface{}); ok && anyExpression.Expression.Match(element) {
+			return true
+		}
+	}
+	return false
+}
+
+func (anyExpression *Any) String() string {
+	return fmt.Sprintf("%s any (%s)", anyExpression.Path, anyExpression.Expression)
+}
+
+// Comparison compares the value at the dotted Path with Value. Equality
+// comparisons support * wildcards, ordering comparisons compare numerically
+// when both sides are numbers. Missing paths never match.
+type Comparison struct {
+	Path     string
+	Operator string
+	Value    string
+	pattern  *regexp.Regexp
+}
+
+func NewComparison(path string, operator string, value string) *Comparison {
+	comparison := &Comparison{Path: path, Operator: operator, Value: value}
+	if strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		comparison.pattern = regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+	}
+	return comparison
+}
+
+func (comparison *Comparison) Match(payload map[string]interface{}) bool {
+	value, ok := lookup(payload, comparison.Path)
+	if !ok {
+		return false
+	}
+	text := formatValue(value)
+	switch comparison.Operator {
+	case "=":
+		return comparison.equals(text)
+	case "!=":
+		return !comparison.equals(text)
+	}
+	order := strings.Compare(text, comparison.Value)
+	left, leftErr := strconv.ParseFloat(text, 64)
+	right, rightErr := strconv.ParseFloat(comparison.Value, 64)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case left < right:
+			order = -1
+		case left > right:
+			order = 1
+		default:
+			order = 0
+		}
+	}
+	switch comparison.Operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func (comparison *Comparison) equals(text string) bool {
+	if comparison.pattern != nil {
+		return comparison.pattern.MatchString(text)
+	}
+	return text == comparison.Value
+}
+
+func (comparison *Comparison) String() string {
+	return fmt.Sprintf("%s%s%s", comparison.Path, comparison.Operator, strconv.Quote(comparison.Value))
+}
+
+func joinExpressions(expressions []Expression, separator string) string {
+	var parts []string
+	for _, expression := range expressions {
+		parts = append(parts, group(expression))
+	}
+	return strings.Join(parts, separator)
+}
+
+func group(expression Expression) string {
+	switch expression.(type) {
+	case *And, *Or:
+		return fmt.Sprintf("(%s)", expression)
+	}
+	return expression.String()
+}
+
+func lookup(payload map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func formatValue(value interface{}) string {
+	switch casted := value.(type) {
+	case string:
+		return casted
+	case float64:
+		return strconv.FormatFloat(casted, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(value)
+}
+
+// Topics returns the values the expression compares topic with for equality.
+func Topics(expression Expression) []string {
+	var topics []string
+	switch casted := expression.(type) {
+	case *Comparison:
+		if casted.Path == "topic" && casted.Operator == "=" {
+			topics = append(topics, casted.Value)
+		}
+	case *And:
+		for _, child := range casted.Expressions {
+			topics = append(topics, Topics(child)...)
+		}
+	case *Or:
+		for _, child := range casted.Expressions {
+			topics = append(topics, Topics(child)...)
+		}
+	}
+	return topics
+}
+
+// SyntaxError reports the position at which a subscription is malformed.
+type SyntaxError = syntax.SyntaxError
+
+type expressionParser struct {
+	syntax.Scanner
+}
+
+// ParseExpression compiles a subscription expression. The grammar is
+//
+//	expression := term ("or" term)*
+//	term       := factor ("and" factor)*
+//	factor     := "not" factor | "(" expression ")" | path "any" "(" expression ")" | path operator value
+//
+// where operator is one of = != < <= > >= and value is a quoted string or a
+// run of characters without whitespace or parentheses.
+func ParseExpression(expression string) (Expression, error) {
+	parser := &expressionParser{syntax.Scanner{Input: expression, IsPathCharacter: isPathCharacter}}
+	result, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.End(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func MustParseExpression(expression string) Expression {
+	result, err := ParseExpression(expression)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (parser *expressionParser) parseOr() (Expression, error) {
+	var expressions []Expression
+	err := parser.Repeat("or", func() error {
+		expression, err := parser.parseAnd()
+		expressions = append(expressions, expression)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+	return &Or{Expressions: expressions}, nil
+}
+
+func (parser *expressionParser) parseAnd() (Expression, error) {
+	var expressions []Expression
+	err := parser.Repeat("and", func() error {
+		expression, err := parser.parseFactor()
+		expressions = append(expressions, expression)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+	return &And{Expressions: expressions}, nil
+}
+
+func (parser *expressionParser) parseFactor() (Expression, error) {
+	if parser.Keyword("not") {
+		expression, err := parser.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expression: expression}, nil
+	}
+	if parser.Symbol("(") {
+		return parser.parseGroup()
+	}
+	path, err := parser.Path()
+	if err != nil {
+		return nil, err
+	}
+	if parser.Keyword("any") {
+		if !parser.Symbol("(") {
+			return nil, parser.Error("expected ( after any")
+		}
+		expression, err := parser.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &Any{Path: path, Expression: expression}, nil
+	}
+	operator := ""
+	for _, candidate := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if parser.Symbol(candidate) {
+			operator = candidate
+			break
+		}
+	}
+	if operator == "" {
+		if parser.AtEnd() {
+			return nil, parser.Error("expected operator after %s", path)
+		}
+		return nil, parser.Error("expected operator after %s, got %q", path, parser.Rest())
+	}
+	value, err := parser.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return NewComparison(path, operator, value), nil
+}
+
+// parseGroup reads the subscription nested in parentheses, by itself or as
+// the condition of any.
+func (parser *expressionParser) parseGroup() (Expression, error) {
+	expression, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.Expect(")"); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+// isPathCharacter allows the - and @ found in the keys of event data.
+func isPathCharacter(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '@' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseValue returns the value of a comparison as text, topics and data are
+// compared as strings.
+func (parser *expressionParser) parseValue() (string, error) {
+	if value, quoted, err := parser.Quoted(); quoted {
+		return value, err
+	}
+	return parser.Word("()")
+}
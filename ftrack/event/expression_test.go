@@ -0,0 +1,89 @@
+package event
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var updatePayload = map[string]interface{}{
+	"topic": "ftrack.update",
+	"source": map[string]interface{}{
+		"user": map[string]interface{}{"username": "john.doe"},
+	},
+	"data": map[string]interface{}{
+		"priority": 3.0,
+		"entities": []interface{}{
+			map[string]interface{}{"entityType": "task", "action": "update", "keys": []interface{}{"name"}},
+			map[string]interface{}{"entityType": "assetversion", "action": "add"},
+		},
+	},
+}
+
+func TestExpression_Match(t *testing.T) {
+	for expression, expected := range map[string]bool{
+		"topic=ftrack.update":                                   true,
+		"topic = 'ftrack.update'":                               true,
+		"topic=ftrack.*":                                        true,
+		"topic=*.update":                                        true,
+		"topic=ftrack.action.*":                                 false,
+		"topic!=ftrack.update":                                  false,
+		`source.user.username="john.doe"`:                       true,
+		`topic=ftrack.update and source.user.username="jane"`:   false,
+		`topic=ftrack.other or source.user.username="john.doe"`: true,
+		"not topic=ftrack.other":                                true,
+		"data.priority>2":                                       true,
+		"data.priority>=3 and data.priority<=3":                 true,
+		"data.priority<10":                                      true,
+		"data.missing=x":                                        false,
+		"data.missing!=x":                                       false,
+		"data.entities any (entityType=task and action=update)": true,
+		"data.entities any (entityType=task and action=add)":    false,
+		"data.entities any (entityType=assetversion) and topic=ftrack.update": true,
+		"topic=ftrack.update and (data.priority=1 or data.priority=3)":        true,
+		"topic=ftrack.update AND NOT (data.priority=3)":                       false,
+	} {
+		parsed, err := ParseExpression(expression)
+		if err != nil {
+			t.Fatalf("%s: %s", expression, err)
+		}
+		assert.Equal(t, expected, parsed.Match(updatePayload), expression)
+		reparsed, err := ParseExpression(parsed.String())
+		assert.Nil(t, err, parsed.String())
+		assert.Equal(t, expected, reparsed.Match(updatePayload), parsed.String())
+	}
+}
+
+func TestExpression_SyntaxErrors(t *testing.T) {
+	for expression, position := range map[string]int{
+		"":                      0,
+		"topic":                 5,
+		"topic=":                6,
+		"topic=a and":           11,
+		"topic=a or (data.x=1":  20,
+		"topic=a)":              7,
+		"topic ~ a":             6,
+		`topic="unterminated`:   6,
+		"data.entities any x=1": 18,
+		"data..x=1":             0,
+	} {
+		_, err := ParseExpression(expression)
+		var syntaxError *SyntaxError
+		if !errors.As(err, &syntaxError) {
+			t.Fatalf("%q: expected SyntaxError, got %v", expression, err)
+		}
+		assert.Equal(t, position, syntaxError.Position, expression)
+		assert.Contains(t, err.Error(), "SyntaxError")
+	}
+}
+
+func TestTopics(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, Topics(MustParseExpression("(topic=a or topic=b) and data.x=1")))
+	assert.Empty(t, Topics(MustParseExpression("not topic=a")))
+}
+
+func TestEvent_Payload(t *testing.T) {
+	event := NewEvent("ftrack.update", map[string]interface{}{"name": "x"})
+	event.Source = &Source{User: &User{Username: "john.doe"}}
+	assert.True(t, MustParseExpression(`topic=ftrack.update and data.name=x and source.user.username="john.doe"`).Match(event.Payload()))
+}
@@ -0,0 +1,591 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const DefaultApplicationId = "ftrack.api.golang"
+
+const (
+	DefaultReconnectDelay    = 5 * time.Second
+	DefaultMaxReconnectDelay = 10 * time.Second
+	DefaultConnectTimeout    = 10 * time.Second
+)
+
+const (
+	TopicSubscribe   = "ftrack.meta.subscribe"
+	TopicUnsubscribe = "ftrack.meta.unsubscribe"
+	TopicReply       = "ftrack.meta.reply"
+)
+
+var ErrClosed = errors.New("event hub closed")
+
+// Handler is called for each event matching a subscription. Returning data
+// publishes it as a reply to the event.
+type Handler func(event *Event) map[string]interface{}
+
+type subscriber struct {
+	id           string
+	subscription string
+	expression   Expression
+	handler      Handler
+	metadata     map[string]interface{}
+}
+
+func newSubscriber(id string, subscription string, expression Expression, handler Handler, applicationId string) *subscriber {
+	return &subscriber{
+		id:           id,
+		subscription: subscription,
+		expression:   expression,
+		handler:      handler,
+		metadata: map[string]interface{}{
+			"id":            id,
+			"applicationId": applicationId,
+		},
+	}
+}
+
+// interested returns the subscribers event should be delivered to, those whose
+// subscription matches it and, for targeted events, whose metadata matches the
+// target.
+func interested(event *Event, subscribers []*subscriber) []*subscriber {
+	var target Expression
+	if event.Target != "" {
+		var err error
+		if target, err = ParseExpression(event.Target); err != nil {
+			return nil
+		}
+	}
+	payload := event.Payload()
+	var matching []*subscriber
+	for _, subscriber := range subscribers {
+		if target != nil && !target.Match(subscriber.metadata) || !subscriber.expression.Match(payload) {
+			continue
+		}
+		matching = append(matching, subscriber)
+	}
+	return matching
+}
+
+// Hub is implemented by EventHub and LocalHub.
+type Hub interface {
+	Publish(event *Event) (string, error)
+	PublishAndWait(ctx context.Context, event *Event) (*Event, error)
+	PublishReply(source *Event, data map[string]interface{}) (string, error)
+	Subscribe(subscription string, handler Handler) (string, error)
+	Unsubscribe(subscriberId string) error
+	Close() error
+}
+
+var _ Hub = (*EventHub)(nil)
+
+type EventHubOptions struct {
+	ApplicationId *string
+	// ReconnectDelay is the delay before the first reconnection attempt, it
+	// doubles with every failed attempt up to MaxReconnectDelay.
+	ReconnectDelay    *time.Duration
+	MaxReconnectDelay *time.Duration
+	Timeout           *time.Duration
+	OnConnect         *func()
+	OnDisconnect      *func(err error)
+}
+
+func (options *EventHubOptions) setDefaults() {
+	if options.ApplicationId == nil {
+		applicationId := DefaultApplicationId
+		options.ApplicationId = &applicationId
+	}
+	if options.ReconnectDelay == nil {
+		delay := DefaultReconnectDelay
+		options.ReconnectDelay = &delay
+	}
+	if options.MaxReconnectDelay == nil {
+		delay := DefaultMaxReconnectDelay
+		options.MaxReconnectDelay = &delay
+	}
+	if options.Timeout == nil {
+		timeout := DefaultConnectTimeout
+		options.Timeout = &timeout
+	}
+}
+
+// EventHub publishes and subscribes to events through the socket.io endpoint
+// of an ftrack server.
+type EventHub struct {
+	Id        string
+	serverUrl string
+	apiUser   string
+	apiKey    string
+	options   EventHubOptions
+	client    *http.Client
+	mu        sync.Mutex
+	conn      *websocketConn
+	// heartbeatTimeout is negotiated in the handshake, the connection is
+	// considered lost when the server sends nothing for this long.
+	heartbeatTimeout time.Duration
+	subscribers      []*subscriber
+	// replySubscriber subscribes to replies with the hub id as subscriber id,
+	// the server only forwards replies targeted at the hub to it then.
+	replySubscriber *subscriber
+	// replies holds the channels of PublishAndWait calls keyed by the id of
+	// the event they wait for replies to.
+	replies   map[string]chan *Event
+	unsent    []*Event
+	events    chan *Event
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewEventHub(session *ftrack.Session, options EventHubOptions) *EventHub {
+	options.setDefaults()
+	id := uuid.Must(uuid.NewV4(), nil).String()
+	return &EventHub{
+		Id:              id,
+		serverUrl:       session.ServerUrl,
+		apiUser:         session.ApiUser,
+		apiKey:          session.ApiKey,
+		options:         options,
+		client:          &http.Client{Timeout: *options.Timeout},
+		replySubscriber: newSubscriber(id, "topic="+TopicReply, nil, nil, *options.ApplicationId),
+		replies:         map[string]chan *Event{},
+		events:          make(chan *Event, 1024),
+		closed:          make(chan struct{}),
+	}
+}
+
+// Connect opens the connection to the server and keeps it open, reconnecting
+// whenever it is lost, until Close is called.
+func (hub *EventHub) Connect(ctx context.Context) error {
+	conn, err := hub.dial(ctx)
+	if err != nil {
+		return err
+	}
+	hub.start(conn)
+	return nil
+}
+
+// ConnectWithRetry is Connect retrying a failed first connection with the
+// reconnection delays until it succeeds, ctx is done or the hub is closed.
+// The first failure is reported to OnDisconnect.
+func (hub *EventHub) ConnectWithRetry(ctx context.Context) error {
+	conn, err := hub.dial(ctx)
+	if err == nil {
+		hub.start(conn)
+		return nil
+	}
+	if err == ErrClosed {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if hub.options.OnDisconnect != nil {
+		(*hub.options.OnDisconnect)(err)
+	}
+	conn, err = hub.reconnect(ctx)
+	if err != nil {
+		return err
+	}
+	hub.start(conn)
+	return nil
+}
+
+func (hub *EventHub) start(conn *websocketConn) {
+	hub.wg.Add(2)
+	go hub.dispatch()
+	go hub.run(conn)
+}
+
+func (hub *EventHub) IsConnected() bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return hub.conn != nil
+}
+
+func (hub *EventHub) Close() error {
+	hub.closeOnce.Do(func() {
+		close(hub.closed)
+		hub.mu.Lock()
+		conn := hub.conn
+		hub.conn = nil
+		hub.mu.Unlock()
+		if conn != nil {
+			_ = conn.WriteText(packet{Type: packetDisconnect}.String())
+			_ = conn.Close()
+		}
+	})
+	hub.wg.Wait()
+	return nil
+}
+
+func (hub *EventHub) isClosed() bool {
+	select {
+	case <-hub.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// header authenticates the handshake and websocket requests, keeping the
+// credentials out of urls that may end up in proxy and server logs.
+func (hub *EventHub) header() http.Header {
+	header := http.Header{}
+	header.Set("ftrack-user", hub.apiUser)
+	header.Set("ftrack-api-key", hub.apiKey)
+	return header
+}
+
+// dial performs the socket.io handshake and waits for the server to confirm
+// the connection, then subscribes to replies, restores subscriptions and sends
+// queued events.
+func (hub *EventHub) dial(ctx context.Context) (*websocketConn, error) {
+	handshake, err := socketIoHandshake(hub.client, hub.serverUrl, hub.header())
+	if err != nil {
+		return nil, err
+	}
+	target, err := websocketUrl(hub.serverUrl, handshake.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialWebsocket(target, hub.header(), *hub.options.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(*hub.options.Timeout)
+	if contextDeadline, ok := ctx.Deadline(); ok && contextDeadline.Before(deadline) {
+		deadline = contextDeadline
+	}
+	_ = conn.SetReadDeadline(deadline)
+	message, err := conn.ReadText()
+	if err == nil {
+		var p packet
+		p, err = parsePacket(message)
+		if err == nil && p.Type != packetConnect {
+			err = errors.New(fmt.Sprintf("unexpected socket.io packet before connect: %s", message))
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	hub.mu.Lock()
+	if hub.isClosed() {
+		hub.mu.Unlock()
+		_ = conn.Close()
+		return nil, ErrClosed
+	}
+	hub.conn = conn
+	hub.heartbeatTimeout = handshake.HeartbeatTimeout
+	pending := []*Event{hub.subscribeEvent(hub.replySubscriber)}
+	for _, subscriber := range hub.subscribers {
+		pending = append(pending, hub.subscribeEvent(subscriber))
+	}
+	unsent := hub.unsent
+	pending = append(pending, unsent...)
+	hub.unsent = nil
+	hub.mu.Unlock()
+	for _, event := range pending {
+		if err := hub.emit(conn, event); err != nil {
+			hub.mu.Lock()
+			hub.conn = nil
+			hub.unsent = append(unsent, hub.unsent...)
+			hub.mu.Unlock()
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if hub.options.OnConnect != nil {
+		(*hub.options.OnConnect)()
+	}
+	return conn, nil
+}
+
+// run reads from conn until the connection is lost and reconnects until the
+// hub is closed.
+func (hub *EventHub) run(conn *websocketConn) {
+	defer hub.wg.Done()
+	for {
+		err := hub.read(conn)
+		hub.mu.Lock()
+		if hub.conn == conn {
+			hub.conn = nil
+		}
+		hub.mu.Unlock()
+		_ = conn.Close()
+		if hub.isClosed() {
+			return
+		}
+		if hub.options.OnDisconnect != nil {
+			(*hub.options.OnDisconnect)(err)
+		}
+		if conn, err = hub.reconnect(context.Background()); err != nil {
+			return
+		}
+	}
+}
+
+// reconnect dials until it succeeds, waiting between attempts, and fails
+// once the hub is closed or ctx is done.
+func (hub *EventHub) reconnect(ctx context.Context) (*websocketConn, error) {
+	delay := *hub.options.ReconnectDelay
+	for {
+		select {
+		case <-hub.closed:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		conn, err := hub.dial(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if err == ErrClosed {
+			return nil, err
+		}
+		delay *= 2
+		if delay > *hub.options.MaxReconnectDelay {
+			delay = *hub.options.MaxReconnectDelay
+		}
+	}
+}
+
+func (hub *EventHub) read(conn *websocketConn) error {
+	hub.mu.Lock()
+	heartbeatTimeout := hub.heartbeatTimeout
+	hub.mu.Unlock()
+	for {
+		if heartbeatTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+		}
+		message, err := conn.ReadText()
+		if err != nil {
+			return err
+		}
+		p, err := parsePacket(message)
+		if err != nil {
+			return err
+		}
+		switch p.Type {
+		case packetHeartbeat:
+			if err := conn.WriteText(packet{Type: packetHeartbeat}.String()); err != nil {
+				return err
+			}
+		case packetEvent:
+			events, err := decodeEvents(p)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				if hub.deliverReply(event) {
+					continue
+				}
+				select {
+				case hub.events <- event:
+				case <-hub.closed:
+					return ErrClosed
+				}
+			}
+		case packetDisconnect:
+			return errors.New("server closed the connection")
+		case packetError:
+			return errors.New(fmt.Sprintf("socket.io error: %s", p.Data))
+		}
+	}
+}
+
+// dispatch calls the handlers of interested subscribers for each received
+// event in the order the events arrived.
+func (hub *EventHub) dispatch() {
+	defer hub.wg.Done()
+	for {
+		select {
+		case <-hub.closed:
+			return
+		case event := <-hub.events:
+			hub.handle(event)
+		}
+	}
+}
+
+func (hub *EventHub) handle(event *Event) {
+	hub.mu.Lock()
+	subscribers := interested(event, hub.subscribers)
+	hub.mu.Unlock()
+	for _, subscriber := range subscribers {
+		data := subscriber.handler(event)
+		if data != nil && event.Topic != TopicReply {
+			_, _ = hub.PublishReply(event, data)
+		}
+	}
+}
+
+func (hub *EventHub) emit(conn *websocketConn, event *Event) error {
+	p, err := newEventPacket(event)
+	if err != nil {
+		return err
+	}
+	return conn.WriteText(p.String())
+}
+
+func (hub *EventHub) prepare(event *Event) {
+	if event.Id == "" {
+		event.Id = uuid.Must(uuid.NewV4(), nil).String()
+	}
+	if event.Data == nil {
+		event.Data = map[string]interface{}{}
+	}
+	event.Source = &Source{
+		Id:            hub.Id,
+		ApplicationId: *hub.options.ApplicationId,
+		User:          &User{Username: hub.apiUser},
+	}
+}
+
+// Publish sends event to the server and returns its id. Events published
+// while disconnected are sent once the connection is established.
+func (hub *EventHub) Publish(event *Event) (string, error) {
+	if hub.isClosed() {
+		return "", ErrClosed
+	}
+	hub.prepare(event)
+	hub.mu.Lock()
+	conn := hub.conn
+	if conn == nil {
+		hub.unsent = append(hub.unsent, event)
+	}
+	hub.mu.Unlock()
+	if conn == nil {
+		return event.Id, nil
+	}
+	return event.Id, hub.emit(conn, event)
+}
+
+// PublishReply publishes data as a reply to the source event, targeted at the
+// hub that published it.
+func (hub *EventHub) PublishReply(source *Event, data map[string]interface{}) (string, error) {
+	reply := NewEvent(TopicReply, data)
+	reply.InReplyToEvent = source.Id
+	if source.Source != nil {
+		reply.Target = fmt.Sprintf("id=%s", source.Source.Id)
+	}
+	return hub.Publish(reply)
+}
+
+// PublishAndWait publishes event and waits for the first reply to it until
+// ctx is done.
+func (hub *EventHub) PublishAndWait(ctx context.Context, event *Event) (*Event, error) {
+	hub.prepare(event)
+	replies := make(chan *Event, 1)
+	hub.mu.Lock()
+	hub.replies[event.Id] = replies
+	hub.mu.Unlock()
+	defer func() {
+		hub.mu.Lock()
+		delete(hub.replies, event.Id)
+		hub.mu.Unlock()
+	}()
+	if _, err := hub.Publish(event); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-hub.closed:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliverReply hands replies to waiting PublishAndWait calls. It runs on the
+// read loop so that handlers can wait for replies without blocking dispatch.
+func (hub *EventHub) deliverReply(event *Event) bool {
+	if event.Topic != TopicReply || event.InReplyToEvent == "" {
+		return false
+	}
+	hub.mu.Lock()
+	replies, ok := hub.replies[event.InReplyToEvent]
+	hub.mu.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case replies <- event:
+	default:
+	}
+	return true
+}
+
+func (hub *EventHub) subscribeEvent(subscriber *subscriber) *Event {
+	event := NewEvent(TopicSubscribe, map[string]interface{}{
+		"subscriber":   subscriber.metadata,
+		"subscription": subscriber.subscription,
+	})
+	hub.prepare(event)
+	return event
+}
+
+// Subscribe calls handler for every event matching the subscription
+// expression and returns the subscriber id to unsubscribe with. The
+// expression must compare topic for equality, the server only forwards events
+// by topic.
+func (hub *EventHub) Subscribe(subscription string, handler Handler) (string, error) {
+	expression, err := ParseExpression(subscription)
+	if err != nil {
+		return "", err
+	}
+	if len(Topics(expression)) == 0 {
+		return "", errors.New(fmt.Sprintf("only subscriptions including a topic are supported: %s", subscription))
+	}
+	subscriber := newSubscriber(uuid.Must(uuid.NewV4(), nil).String(), subscription, expression, handler, *hub.options.ApplicationId)
+	hub.mu.Lock()
+	hub.subscribers = append(hub.subscribers, subscriber)
+	conn := hub.conn
+	hub.mu.Unlock()
+	if conn != nil {
+		if err := hub.emit(conn, hub.subscribeEvent(subscriber)); err != nil {
+			return subscriber.id, err
+		}
+	}
+	return subscriber.id, nil
+}
+
+func (hub *EventHub) Unsubscribe(subscriberId string) error {
+	hub.mu.Lock()
+	var removed *subscriber
+	for i, subscriber := range hub.subscribers {
+		if subscriber.id == subscriberId {
+			removed = subscriber
+			hub.subscribers = append(hub.subscribers[:i], hub.subscribers[i+1:]...)
+			break
+		}
+	}
+	conn := hub.conn
+	hub.mu.Unlock()
+	if removed == nil {
+		return errors.New(fmt.Sprintf("no subscriber with id %s", subscriberId))
+	}
+	if conn == nil {
+		return nil
+	}
+	event := NewEvent(TopicUnsubscribe, map[string]interface{}{
+		"subscriber": removed.metadata,
+	})
+	hub.prepare(event)
+	return hub.emit(conn, event)
+}
@@ -0,0 +1,474 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// socketServer is a minimal socket.io 0.9 server relaying ftrack events.
+type socketServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	handshakes int
+	// unavailable is the number of handshakes failing before the server
+	// accepts connections.
+	unavailable int
+	conns       chan *websocketConn
+	received    chan *Event
+	heartbeats  chan struct{}
+	// route makes the server forward received events to the connections
+	// with a subscriber matching their topic and target, like ftrack does.
+	route       bool
+	subscribers map[*websocketConn][]*subscriber
+}
+
+func newSocketServer(t *testing.T) *socketServer {
+	server := &socketServer{
+		conns:       make(chan *websocketConn, 10),
+		received:    make(chan *Event, 100),
+		heartbeats:  make(chan struct{}, 10),
+		subscribers: map[*websocketConn][]*subscriber{},
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *socketServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("ftrack-user") != "user" || r.Header.Get("ftrack-api-key") != "key" || r.URL.RawQuery != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/socket.io/1/" {
+		server.mu.Lock()
+		if server.unavailable > 0 {
+			server.unavailable--
+			server.mu.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.handshakes++
+		sessionId := fmt.Sprintf("session-%d", server.handshakes)
+		server.mu.Unlock()
+		_, _ = fmt.Fprintf(w, "%s:60:60:websocket,xhr-polling", sessionId)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/socket.io/1/websocket/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	conn, buffer, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(buffer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+	_ = buffer.Flush()
+	ws := &websocketConn{conn: conn, reader: bufio.NewReader(buffer)}
+	_ = ws.WriteText(packet{Type: packetConnect}.String())
+	server.conns <- ws
+	go func() {
+		for {
+			message, err := ws.ReadText()
+			if err != nil {
+				return
+			}
+			p, err := parsePacket(message)
+			if err != nil {
+				return
+			}
+			switch p.Type {
+			case packetHeartbeat:
+				server.heartbeats <- struct{}{}
+			case packetEvent:
+				events, _ := decodeEvents(p)
+				for _, event := range events {
+					server.subscribe(ws, event)
+					if server.route {
+						server.forward(event)
+					}
+					server.received <- event
+				}
+			}
+		}
+	}()
+}
+
+// subscribe registers the subscriber of subscribe events sent over conn.
+func (server *socketServer) subscribe(conn *websocketConn, event *Event) {
+	if event.Topic != TopicSubscribe {
+		return
+	}
+	subscription, _ := event.Data["subscription"].(string)
+	expression, err := ParseExpression(subscription)
+	if err != nil {
+		return
+	}
+	metadata, _ := event.Data["subscriber"].(map[string]interface{})
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.subscribers[conn] = append(server.subscribers[conn], &subscriber{
+		subscription: subscription,
+		expression:   expression,
+		metadata:     metadata,
+	})
+}
+
+// forward sends event to every connection interested in it.
+func (server *socketServer) forward(event *Event) {
+	p, err := newEventPacket(event)
+	if err != nil {
+		return
+	}
+	server.mu.Lock()
+	var conns []*websocketConn
+	for conn, subscribers := range server.subscribers {
+		if len(interested(event, subscribers)) > 0 {
+			conns = append(conns, conn)
+		}
+	}
+	server.mu.Unlock()
+	for _, conn := range conns {
+		_ = conn.WriteText(p.String())
+	}
+}
+
+func (server *socketServer) send(t *testing.T, conn *websocketConn, event *Event) {
+	p, err := newEventPacket(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteText(p.String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (server *socketServer) next(t *testing.T, topic string) *Event {
+	for {
+		select {
+		case event := <-server.received:
+			if event.Topic == topic {
+				return event
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s event", topic)
+		}
+	}
+}
+
+func (server *socketServer) nextConn(t *testing.T) *websocketConn {
+	select {
+	case conn := <-server.conns:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for connection")
+	}
+	return nil
+}
+
+func newTestHub(server *socketServer, options EventHubOptions) *EventHub {
+	return NewEventHub(&ftrack.Session{ServerUrl: server.URL, ApiUser: "user", ApiKey: "key"}, options)
+}
+
+func TestEventHub_SubscribeAndReply(t *testing.T) {
+	server := newSocketServer(t)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+	assert.True(t, hub.IsConnected())
+	replies := server.next(t, TopicSubscribe)
+	assert.Equal(t, "topic="+TopicReply, replies.Data["subscription"])
+	assert.Equal(t, hub.Id, replies.Data["subscriber"].(map[string]interface{})["id"])
+
+	received := make(chan *Event, 10)
+	subscriberId, err := hub.Subscribe("topic=ftrack.update", func(event *Event) map[string]interface{} {
+		received <- event
+		return map[string]interface{}{"handled": true}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscribe := server.next(t, TopicSubscribe)
+	assert.Equal(t, "topic=ftrack.update", subscribe.Data["subscription"])
+	assert.Equal(t, subscriberId, subscribe.Data["subscriber"].(map[string]interface{})["id"])
+	assert.Equal(t, hub.Id, subscribe.Source.Id)
+	assert.Equal(t, "user", subscribe.Source.User.Username)
+
+	other := NewEvent("ftrack.other", nil)
+	update := NewEvent("ftrack.update", map[string]interface{}{"entities": []interface{}{}})
+	update.Source = &Source{Id: "publisher"}
+	server.send(t, conn, other)
+	server.send(t, conn, update)
+	select {
+	case event := <-received:
+		assert.Equal(t, update.Id, event.Id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+	reply := server.next(t, TopicReply)
+	assert.Equal(t, update.Id, reply.InReplyToEvent)
+	assert.Equal(t, "id=publisher", reply.Target)
+	assert.Equal(t, true, reply.Data["handled"])
+	assert.Len(t, received, 0)
+
+	assert.Nil(t, hub.Unsubscribe(subscriberId))
+	unsubscribe := server.next(t, TopicUnsubscribe)
+	assert.Equal(t, subscriberId, unsubscribe.Data["subscriber"].(map[string]interface{})["id"])
+	assert.NotNil(t, hub.Unsubscribe(subscriberId))
+}
+
+func TestEventHub_Target(t *testing.T) {
+	server := newSocketServer(t)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+
+	received := make(chan string, 10)
+	handler := func(name string) Handler {
+		return func(event *Event) map[string]interface{} {
+			received <- name
+			return nil
+		}
+	}
+	first, _ := hub.Subscribe("topic=ftrack.test", handler("first"))
+	_, _ = hub.Subscribe("topic=ftrack.test", handler("second"))
+	targeted := NewEvent("ftrack.test", nil)
+	targeted.Target = fmt.Sprintf("id=%s", first)
+	server.send(t, conn, targeted)
+	select {
+	case name := <-received:
+		assert.Equal(t, "first", name)
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+	select {
+	case name := <-received:
+		t.Fatalf("targeted event delivered to %s", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventHub_Heartbeat(t *testing.T) {
+	server := newSocketServer(t)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+	assert.Nil(t, conn.WriteText(packet{Type: packetHeartbeat}.String()))
+	select {
+	case <-server.heartbeats:
+	case <-time.After(2 * time.Second):
+		t.Fatal("heartbeat not answered")
+	}
+}
+
+func TestEventHub_Reconnect(t *testing.T) {
+	server := newSocketServer(t)
+	delay := 10 * time.Millisecond
+	disconnected := make(chan error, 1)
+	onDisconnect := func(err error) {
+		disconnected <- err
+	}
+	hub := newTestHub(server, EventHubOptions{ReconnectDelay: &delay, OnDisconnect: &onDisconnect})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+	server.next(t, TopicSubscribe)
+	_, err := hub.Subscribe("topic=ftrack.update", func(event *Event) map[string]interface{} { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.next(t, TopicSubscribe)
+
+	_ = conn.Close()
+	<-disconnected
+	server.nextConn(t)
+	replies := server.next(t, TopicSubscribe)
+	assert.Equal(t, "topic="+TopicReply, replies.Data["subscription"])
+	subscribe := server.next(t, TopicSubscribe)
+	assert.Equal(t, "topic=ftrack.update", subscribe.Data["subscription"])
+
+	id, err := hub.Publish(NewEvent("ftrack.custom", map[string]interface{}{"value": 1.0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := server.next(t, "ftrack.custom")
+	assert.Equal(t, id, published.Id)
+	assert.Equal(t, 1.0, published.Data["value"])
+}
+
+func TestEventHub_ConnectWithRetry(t *testing.T) {
+	server := newSocketServer(t)
+	server.unavailable = 2
+	delay := 10 * time.Millisecond
+	disconnected := make(chan error, 10)
+	onDisconnect := func(err error) {
+		disconnected <- err
+	}
+	hub := newTestHub(server, EventHubOptions{ReconnectDelay: &delay, OnDisconnect: &onDisconnect})
+	assert.NotNil(t, hub.Connect(context.Background()))
+	if err := hub.ConnectWithRetry(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	server.nextConn(t)
+	assert.True(t, hub.IsConnected())
+	assert.Len(t, disconnected, 1)
+
+	server.mu.Lock()
+	server.unavailable = 1000
+	server.mu.Unlock()
+	unavailable := newTestHub(server, EventHubOptions{ReconnectDelay: &delay})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, unavailable.ConnectWithRetry(ctx))
+	assert.Nil(t, unavailable.Close())
+}
+
+func TestEventHub_Errors(t *testing.T) {
+	server := newSocketServer(t)
+	hub := NewEventHub(&ftrack.Session{ServerUrl: server.URL, ApiUser: "user", ApiKey: "wrong"}, EventHubOptions{})
+	assert.NotNil(t, hub.Connect(context.Background()))
+
+	_, err := hub.Subscribe("data.name=x", func(event *Event) map[string]interface{} { return nil })
+	assert.NotNil(t, err)
+
+	assert.Nil(t, hub.Close())
+	_, err = hub.Publish(NewEvent("ftrack.custom", nil))
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestEventHub_PublishAndWait(t *testing.T) {
+	server := newSocketServer(t)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+
+	go func() {
+		request := server.next(t, "ftrack.custom.request")
+		other := NewEvent(TopicReply, map[string]interface{}{"answer": "unrelated"})
+		other.InReplyToEvent = "other"
+		server.send(t, conn, other)
+		reply := NewEvent(TopicReply, map[string]interface{}{"answer": 42.0})
+		reply.InReplyToEvent = request.Id
+		server.send(t, conn, reply)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := hub.PublishAndWait(ctx, NewEvent("ftrack.custom.request", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 42.0, reply.Data["answer"])
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = hub.PublishAndWait(ctx, NewEvent("ftrack.custom.request", nil))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestEventHub_PublishAndWaitRouted(t *testing.T) {
+	server := newSocketServer(t)
+	server.route = true
+	responder := newTestHub(server, EventHubOptions{})
+	if err := responder.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	server.nextConn(t)
+	_, err := responder.Subscribe("topic=ftrack.custom.request", func(event *Event) map[string]interface{} {
+		return map[string]interface{}{"answer": 42.0}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.next(t, TopicSubscribe)
+	server.next(t, TopicSubscribe)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	server.nextConn(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := hub.PublishAndWait(ctx, NewEvent("ftrack.custom.request", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 42.0, reply.Data["answer"])
+	assert.Equal(t, "id="+hub.Id, reply.Target)
+}
+
+func TestEventHub_PublishAndWaitFromHandler(t *testing.T) {
+	server := newSocketServer(t)
+	hub := newTestHub(server, EventHubOptions{})
+	if err := hub.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+	conn := server.nextConn(t)
+
+	results := make(chan *Event, 1)
+	_, err := hub.Subscribe("topic=ftrack.custom.trigger", func(event *Event) map[string]interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		reply, err := hub.PublishAndWait(ctx, NewEvent("ftrack.custom.request", nil))
+		if err != nil {
+			t.Error(err)
+		}
+		results <- reply
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.next(t, TopicSubscribe)
+	server.send(t, conn, NewEvent("ftrack.custom.trigger", nil))
+	request := server.next(t, "ftrack.custom.request")
+	assert.Equal(t, hub.Id, request.Source.Id)
+
+	// A peer answers the request with PublishReply, which targets this hub.
+	peer := newTestHub(server, EventHubOptions{})
+	if err := peer.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	server.nextConn(t)
+	_, err = peer.PublishReply(request, map[string]interface{}{"ok": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := server.next(t, TopicReply)
+	assert.Equal(t, request.Id, reply.InReplyToEvent)
+	assert.Equal(t, "id="+hub.Id, reply.Target)
+	server.send(t, conn, reply)
+	select {
+	case result := <-results:
+		assert.Equal(t, true, result.Data["ok"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("reply not delivered to handler")
+	}
+}
@@ -0,0 +1,299 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"sync"
+)
+
+type LocalHubOptions struct {
+	ApplicationId *string
+	// Username is set as source user of published events without a source.
+	Username *string
+}
+
+func (options *LocalHubOptions) setDefaults() {
+	if options.ApplicationId == nil {
+		applicationId := DefaultApplicationId
+		options.ApplicationId = &applicationId
+	}
+	if options.Username == nil {
+		username := ""
+		options.Username = &username
+	}
+}
+
+// queuedEvent is delivered to all interested subscribers, or only to the
+// subscriber with subscriberId when it is set.
+type queuedEvent struct {
+	event        *Event
+	subscriberId string
+}
+
+type topicQueue struct {
+	events  []queuedEvent
+	running bool
+}
+
+// LocalHub is an in-process Hub. Events are delivered to subscribers in the
+// order they were published per topic, each topic on its own goroutine.
+// Targeted events are only delivered to subscribers whose metadata matches the
+// target expression.
+type LocalHub struct {
+	Id          string
+	options     LocalHubOptions
+	mu          sync.Mutex
+	idle        *sync.Cond
+	subscribers []*subscriber
+	replies     map[string]chan *Event
+	topics      map[string]*topicQueue
+	pending     int
+	closed      bool
+	// forward replaces local delivery of published events, it is set when the
+	// hub is connected to a Bridge.
+	forward       func(event *Event) error
+	onSubscribe   func(subscriber *subscriber) error
+	onUnsubscribe func(subscriber *subscriber) error
+	onClose       func() error
+}
+
+var _ Hub = (*LocalHub)(nil)
+
+func NewLocalHub(options LocalHubOptions) *LocalHub {
+	options.setDefaults()
+	hub := &LocalHub{
+		Id:      uuid.Must(uuid.NewV4(), nil).String(),
+		options: options,
+		replies: map[string]chan *Event{},
+		topics:  map[string]*topicQueue{},
+	}
+	hub.idle = sync.NewCond(&hub.mu)
+	return hub
+}
+
+func (hub *LocalHub) prepare(event *Event) {
+	if event.Id == "" {
+		event.Id = uuid.Must(uuid.NewV4(), nil).String()
+	}
+	if event.Data == nil {
+		event.Data = map[string]interface{}{}
+	}
+	if event.Source == nil {
+		event.Source = &Source{
+			Id:            hub.Id,
+			ApplicationId: *hub.options.ApplicationId,
+			User:          &User{Username: *hub.options.Username},
+		}
+	}
+}
+
+// Publish queues event for delivery and returns its id. Events without a
+// source are published with the hub as source.
+func (hub *LocalHub) Publish(event *Event) (string, error) {
+	hub.prepare(event)
+	hub.mu.Lock()
+	closed := hub.closed
+	forward := hub.forward
+	hub.mu.Unlock()
+	if closed {
+		return "", ErrClosed
+	}
+	if forward != nil {
+		return event.Id, forward(event)
+	}
+	return event.Id, hub.receive(event)
+}
+
+// receive delivers an event to waiting PublishAndWait calls or queues it for
+// the subscribers.
+func (hub *LocalHub) receive(event *Event) error {
+	return hub.receiveFor(event, "")
+}
+
+// receiveFor is receive restricted to a single subscriber when subscriberId
+// is set, used by hubs connected to a Bridge which forwards a copy of each
+// event per subscriber.
+func (hub *LocalHub) receiveFor(event *Event, subscriberId string) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return ErrClosed
+	}
+	if event.Topic == TopicReply && event.InReplyToEvent != "" {
+		if replies, ok := hub.replies[event.InReplyToEvent]; ok {
+			select {
+			case replies <- event:
+			default:
+			}
+			return nil
+		}
+	}
+	queue, ok := hub.topics[event.Topic]
+	if !ok {
+		queue = &topicQueue{}
+		hub.topics[event.Topic] = queue
+	}
+	queue.events = append(queue.events, queuedEvent{event: event, subscriberId: subscriberId})
+	hub.pending++
+	if !queue.running {
+		queue.running = true
+		go hub.drain(queue)
+	}
+	return nil
+}
+
+func (hub *LocalHub) drain(queue *topicQueue) {
+	for {
+		hub.mu.Lock()
+		if len(queue.events) == 0 {
+			queue.running = false
+			hub.mu.Unlock()
+			return
+		}
+		queued := queue.events[0]
+		queue.events = queue.events[1:]
+		var subscribers []*subscriber
+		for _, subscriber := range hub.subscribers {
+			if queued.subscriberId == "" || subscriber.id == queued.subscriberId {
+				subscribers = append(subscribers, subscriber)
+			}
+		}
+		hub.mu.Unlock()
+
+		hub.handle(queued.event, subscribers)
+
+		hub.mu.Lock()
+		hub.pending--
+		if hub.pending == 0 {
+			hub.idle.Broadcast()
+		}
+		hub.mu.Unlock()
+	}
+}
+
+func (hub *LocalHub) handle(event *Event, subscribers []*subscriber) {
+	for _, subscriber := range interested(event, subscribers) {
+		data := subscriber.handler(event)
+		if data != nil && event.Topic != TopicReply {
+			_, _ = hub.PublishReply(event, data)
+		}
+	}
+}
+
+// Wait blocks until all published events have been handled, including events
+// published by the handlers.
+func (hub *LocalHub) Wait() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for hub.pending > 0 {
+		hub.idle.Wait()
+	}
+}
+
+func (hub *LocalHub) PublishReply(source *Event, data map[string]interface{}) (string, error) {
+	reply := NewEvent(TopicReply, data)
+	reply.InReplyToEvent = source.Id
+	if source.Source != nil {
+		reply.Target = fmt.Sprintf("id=%s", source.Source.Id)
+	}
+	return hub.Publish(reply)
+}
+
+func (hub *LocalHub) PublishAndWait(ctx context.Context, event *Event) (*Event, error) {
+	hub.prepare(event)
+	replies := make(chan *Event, 1)
+	hub.mu.Lock()
+	hub.replies[event.Id] = replies
+	hub.mu.Unlock()
+	defer func() {
+		hub.mu.Lock()
+		delete(hub.replies, event.Id)
+		hub.mu.Unlock()
+	}()
+	if _, err := hub.Publish(event); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (hub *LocalHub) Subscribe(subscription string, handler Handler) (string, error) {
+	expression, err := ParseExpression(subscription)
+	if err != nil {
+		return "", err
+	}
+	subscriber := newSubscriber(uuid.Must(uuid.NewV4(), nil).String(), subscription, expression, handler, *hub.options.ApplicationId)
+	if err := hub.addSubscriber(subscriber); err != nil {
+		return "", err
+	}
+	return subscriber.id, nil
+}
+
+func (hub *LocalHub) addSubscriber(subscriber *subscriber) error {
+	hub.mu.Lock()
+	if hub.closed {
+		hub.mu.Unlock()
+		return ErrClosed
+	}
+	hub.subscribers = append(hub.subscribers, subscriber)
+	onSubscribe := hub.onSubscribe
+	hub.mu.Unlock()
+	if onSubscribe != nil {
+		if err := onSubscribe(subscriber); err != nil {
+			hub.mu.Lock()
+			for i, existing := range hub.subscribers {
+				if existing == subscriber {
+					hub.subscribers = append(hub.subscribers[:i:i], hub.subscribers[i+1:]...)
+					break
+				}
+			}
+			hub.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+func (hub *LocalHub) Unsubscribe(subscriberId string) error {
+	hub.mu.Lock()
+	var removed *subscriber
+	for i, subscriber := range hub.subscribers {
+		if subscriber.id == subscriberId {
+			removed = subscriber
+			hub.subscribers = append(hub.subscribers[:i:i], hub.subscribers[i+1:]...)
+			break
+		}
+	}
+	onUnsubscribe := hub.onUnsubscribe
+	hub.mu.Unlock()
+	if removed == nil {
+		return errors.New(fmt.Sprintf("no subscriber with id %s", subscriberId))
+	}
+	if onUnsubscribe != nil {
+		return onUnsubscribe(removed)
+	}
+	return nil
+}
+
+// Close waits for queued events to be handled and stops accepting new ones.
+func (hub *LocalHub) Close() error {
+	hub.Wait()
+	hub.mu.Lock()
+	if hub.closed {
+		hub.mu.Unlock()
+		return nil
+	}
+	hub.closed = true
+	onClose := hub.onClose
+	hub.mu.Unlock()
+	if onClose != nil {
+		return onClose()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLocalHub_Ordering(t *testing.T) {
+	hub := NewLocalHub(LocalHubOptions{})
+	defer hub.Close()
+	var mu sync.Mutex
+	received := map[string][]float64{}
+	_, err := hub.Subscribe("topic=ftrack.test.*", func(event *Event) map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		received[event.Topic] = append(received[event.Topic], event.Data["index"].(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hub.Subscribe("topic=ftrack.test.a and data.index>=98", func(event *Event) map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		received["filtered"] = append(received["filtered"], event.Data["index"].(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []float64
+	for i := 0; i < 100; i++ {
+		expected = append(expected, float64(i))
+		for _, topic := range []string{"ftrack.test.a", "ftrack.test.b", "ftrack.other"} {
+			// Handlers see the decoded JSON form of the data, as over the wire.
+			if _, err := hub.Publish(NewEvent(topic, map[string]interface{}{"index": float64(i)})); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	hub.Wait()
+	assert.Equal(t, expected, received["ftrack.test.a"])
+	assert.Equal(t, expected, received["ftrack.test.b"])
+	assert.Equal(t, []float64{98, 99}, received["filtered"])
+	assert.Empty(t, received["ftrack.other"])
+}
+
+func TestLocalHub_Replies(t *testing.T) {
+	hub := NewLocalHub(LocalHubOptions{Username: stringPointer("john.doe")})
+	defer hub.Close()
+	subscriberId, err := hub.Subscribe("topic=ftrack.test.request", func(event *Event) map[string]interface{} {
+		return map[string]interface{}{"greeting": fmt.Sprintf("hello %s", event.Source.User.Username)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := hub.PublishAndWait(ctx, NewEvent("ftrack.test.request", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hello john.doe", reply.Data["greeting"])
+
+	assert.Nil(t, hub.Unsubscribe(subscriberId))
+	assert.NotNil(t, hub.Unsubscribe(subscriberId))
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = hub.PublishAndWait(ctx, NewEvent("ftrack.test.request", nil))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestLocalHub_Target(t *testing.T) {
+	hub := NewLocalHub(LocalHubOptions{})
+	var mu sync.Mutex
+	var received []string
+	handler := func(name string) Handler {
+		return func(event *Event) map[string]interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, name)
+			return nil
+		}
+	}
+	first, _ := hub.Subscribe("topic=ftrack.test", handler("first"))
+	_, _ = hub.Subscribe("topic=ftrack.test", handler("second"))
+	event := NewEvent("ftrack.test", nil)
+	event.Target = fmt.Sprintf("id=%s", first)
+	_, _ = hub.Publish(event)
+	assert.Nil(t, hub.Close())
+	assert.Equal(t, []string{"first"}, received)
+
+	_, err := hub.Publish(NewEvent("ftrack.test", nil))
+	assert.Equal(t, ErrClosed, err)
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
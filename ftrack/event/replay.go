@@ -0,0 +1,117 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	uuid "github.com/satori/go.uuid"
+	"sync"
+	"time"
+)
+
+// EventFromEntity converts an Event entity returned by Session.QueryEvents to
+// the event published at the time. Data stored as something else than a JSON
+// object is kept under the "value" key.
+func EventFromEntity(entity map[string]interface{}) *Event {
+	event := &Event{Data: map[string]interface{}{}}
+	event.Id, _ = entity["id"].(string)
+	event.Topic, _ = entity["action"].(string)
+	event.Sent = entity["created_at"]
+	switch data := entity["data"].(type) {
+	case string:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+			event.Data["value"] = data
+		} else if object, ok := decoded.(map[string]interface{}); ok {
+			event.Data = object
+		} else if decoded != nil {
+			event.Data["value"] = decoded
+		}
+	case map[string]interface{}:
+		event.Data = data
+	}
+	if userId, ok := entity["user_id"].(string); ok && userId != "" {
+		event.Source = &Source{User: &User{Id: userId}}
+	}
+	return event
+}
+
+// Replayer feeds past events through handlers subscribed the same way as on
+// a hub. Replies returned by the handlers are discarded.
+type Replayer struct {
+	mu          sync.Mutex
+	subscribers []*subscriber
+}
+
+func NewReplayer() *Replayer {
+	return &Replayer{}
+}
+
+func (replayer *Replayer) Subscribe(subscription string, handler Handler) (string, error) {
+	expression, err := ParseExpression(subscription)
+	if err != nil {
+		return "", err
+	}
+	subscriber := newSubscriber(uuid.Must(uuid.NewV4(), nil).String(), subscription, expression, handler, DefaultApplicationId)
+	replayer.mu.Lock()
+	replayer.subscribers = append(replayer.subscribers, subscriber)
+	replayer.mu.Unlock()
+	return subscriber.id, nil
+}
+
+func (replayer *Replayer) Unsubscribe(subscriberId string) error {
+	replayer.mu.Lock()
+	defer replayer.mu.Unlock()
+	for i, subscriber := range replayer.subscribers {
+		if subscriber.id == subscriberId {
+			replayer.subscribers = append(replayer.subscribers[:i:i], replayer.subscribers[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("no subscriber with id %s", subscriberId))
+}
+
+// Replay passes events in order to the matching handlers and returns the
+// number of events handled by at least one of them. It stops early when ctx is
+// done.
+func (replayer *Replayer) Replay(ctx context.Context, events []*Event) (int, error) {
+	replayer.mu.Lock()
+	subscribers := append([]*subscriber(nil), replayer.subscribers...)
+	replayer.mu.Unlock()
+	handled := 0
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return handled, err
+		}
+		payload := event.Payload()
+		matched := false
+		for _, subscriber := range subscribers {
+			if subscriber.expression.Match(payload) {
+				subscriber.handler(event)
+				matched = true
+			}
+		}
+		if matched {
+			handled++
+		}
+	}
+	return handled, nil
+}
+
+// ReplayEvents queries the events stored between since and until with
+// Session.QueryEventPages and replays them page by page.
+func (replayer *Replayer) ReplayEvents(ctx context.Context, session *ftrack.Session, topic string, since time.Time, until time.Time, filter string) (int, error) {
+	handled := 0
+	err := session.QueryEventPages(topic, since, until, filter, func(page []map[string]interface{}) error {
+		events := make([]*Event, len(page))
+		for i, entity := range page {
+			events[i] = EventFromEntity(entity)
+		}
+		count, err := replayer.Replay(ctx, events)
+		handled += count
+		return err
+	})
+	return handled, err
+}
@@ -0,0 +1,131 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	"github.com/conducte/ftrack-golang-api/ftrack/ftracktest"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventFromEntity(t *testing.T) {
+	event := EventFromEntity(map[string]interface{}{
+		"id":         "1",
+		"action":     "ftrack.update",
+		"data":       `{"entities": [{"entityType": "task"}]}`,
+		"created_at": "2020-05-01T08:00:00",
+		"user_id":    "u",
+	})
+	assert.Equal(t, "1", event.Id)
+	assert.Equal(t, "ftrack.update", event.Topic)
+	assert.Equal(t, "2020-05-01T08:00:00", event.Sent)
+	assert.Equal(t, "u", event.Source.User.Id)
+	assert.Len(t, event.Data["entities"], 1)
+
+	assert.Equal(t, map[string]interface{}{"value": "plain"}, EventFromEntity(map[string]interface{}{"data": "plain"}).Data)
+	assert.Equal(t, map[string]interface{}{"value": []interface{}{1.0}}, EventFromEntity(map[string]interface{}{"data": "[1]"}).Data)
+	assert.Equal(t, map[string]interface{}{}, EventFromEntity(map[string]interface{}{"data": "null"}).Data)
+	assert.Nil(t, EventFromEntity(map[string]interface{}{}).Source)
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	replayer := NewReplayer()
+	var updates []string
+	updateId, err := replayer.Subscribe("topic=ftrack.update", func(event *Event) map[string]interface{} {
+		updates = append(updates, event.Id)
+		return map[string]interface{}{"ignored": true}
+	})
+	assert.Nil(t, err)
+
+	// Backfilling through a durable consumer persists the events like the
+	// live subscription does.
+	consumer, err := NewDurableConsumer(filepath.Join(tempDir(t), "wal"), func(event *Event) error {
+		return nil
+	}, DurableConsumerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = consumer.Close() }()
+	_, err = replayer.Subscribe("topic=ftrack.* and data.kind=asset", consumer.Handler())
+	assert.Nil(t, err)
+
+	events := []*Event{
+		{Id: "1", Topic: "ftrack.update", Data: map[string]interface{}{"kind": "asset"}},
+		{Id: "2", Topic: "ftrack.other", Data: map[string]interface{}{"kind": "shot"}},
+		{Id: "3", Topic: "ftrack.update", Data: map[string]interface{}{}},
+	}
+	handled, err := replayer.Replay(context.Background(), events)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, handled)
+	assert.Equal(t, []string{"1", "3"}, updates)
+	assert.Equal(t, 1, consumer.Pending())
+
+	// Events already persisted are not added twice.
+	assert.Nil(t, replayer.Unsubscribe(updateId))
+	handled, err = replayer.Replay(context.Background(), events)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 1, consumer.Pending())
+	assert.NotNil(t, replayer.Unsubscribe(updateId))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handled, err = replayer.Replay(ctx, events)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, handled)
+}
+
+func TestReplayer_ReplayEvents(t *testing.T) {
+	server, err := ftracktest.NewServer(ftracktest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	count := ftrack.DefaultQueryPageSize + 10
+	start := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		_, err := server.Store.Add("Event", map[string]interface{}{
+			"id":         fmt.Sprint(i),
+			"action":     "ftrack.update",
+			"data":       fmt.Sprintf(`{"index": %d}`, i),
+			"created_at": start.Add(time.Duration(i) * time.Second).Format("2006-01-02T15:04:05"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	session, err := ftrack.NewSession(ftrack.SessionConfig{ServerUrl: server.URL, ApiUser: server.ApiUser, ApiKey: server.ApiKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer := NewReplayer()
+	var indexes []float64
+	_, _ = replayer.Subscribe("topic=ftrack.update", func(event *Event) map[string]interface{} {
+		indexes = append(indexes, event.Data["index"].(float64))
+		return nil
+	})
+	handled, err := replayer.ReplayEvents(context.Background(), session, "ftrack.*", time.Time{}, time.Time{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, count, handled)
+	if assert.Len(t, indexes, count) {
+		assert.Equal(t, 0.0, indexes[0])
+		assert.Equal(t, float64(count-1), indexes[count-1])
+	}
+	var queries int
+	for _, batch := range server.Batches() {
+		if batch[0]["action"] == "query" {
+			queries++
+		}
+	}
+	assert.Equal(t, 2, queries, "events should be queried page by page")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handled, err = replayer.ReplayEvents(ctx, session, "ftrack.*", time.Time{}, time.Time{}, "")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, handled)
+}
@@ -0,0 +1,161 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Packet types of the socket.io 0.9 protocol spoken by the ftrack server.
+const (
+	packetDisconnect = 0
+	packetConnect    = 1
+	packetHeartbeat  = 2
+	packetMessage    = 3
+	packetJson       = 4
+	packetEvent      = 5
+	packetAck        = 6
+	packetError      = 7
+	packetNoop       = 8
+)
+
+const eventName = "ftrack.event"
+
+type packet struct {
+	Type     int
+	Id       string
+	Endpoint string
+	Data     string
+}
+
+func (p packet) String() string {
+	encoded := fmt.Sprintf("%d:%s:%s", p.Type, p.Id, p.Endpoint)
+	if p.Data != "" {
+		encoded += ":" + p.Data
+	}
+	return encoded
+}
+
+func parsePacket(message string) (packet, error) {
+	parts := strings.SplitN(message, ":", 4)
+	if len(parts) < 3 {
+		return packet{}, errors.New(fmt.Sprintf("malformed socket.io packet: %s", message))
+	}
+	packetType, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return packet{}, errors.New(fmt.Sprintf("malformed socket.io packet type: %s", message))
+	}
+	p := packet{Type: packetType, Id: parts[1], Endpoint: parts[2]}
+	if len(parts) == 4 {
+		p.Data = parts[3]
+	}
+	return p, nil
+}
+
+type eventPacketData struct {
+	Name string            `json:"name"`
+	Args []json.RawMessage `json:"args"`
+}
+
+func newEventPacket(event *Event) (packet, error) {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return packet{}, err
+	}
+	data, err := json.Marshal(eventPacketData{Name: eventName, Args: []json.RawMessage{encoded}})
+	if err != nil {
+		return packet{}, err
+	}
+	return packet{Type: packetEvent, Data: string(data)}, nil
+}
+
+// decodeEvents returns the ftrack events carried by an event packet.
+func decodeEvents(p packet) ([]*Event, error) {
+	var data eventPacketData
+	if err := json.Unmarshal([]byte(p.Data), &data); err != nil {
+		return nil, err
+	}
+	if data.Name != eventName {
+		return nil, nil
+	}
+	var events []*Event
+	for _, arg := range data.Args {
+		var event Event
+		if err := json.Unmarshal(arg, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+type handshake struct {
+	SessionId        string
+	HeartbeatTimeout time.Duration
+	Transports       []string
+}
+
+// socketIoHandshake negotiates a socket.io session, authenticating with the
+// api credentials passed as headers.
+func socketIoHandshake(client *http.Client, serverUrl string, header http.Header) (*handshake, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/socket.io/1/", strings.TrimRight(serverUrl, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("socket.io handshake failed: %s %s", response.Status, strings.TrimSpace(string(body))))
+	}
+	parts := strings.Split(strings.TrimSpace(string(body)), ":")
+	if len(parts) < 4 || parts[0] == "" {
+		return nil, errors.New(fmt.Sprintf("malformed socket.io handshake: %s", body))
+	}
+	result := &handshake{SessionId: parts[0], Transports: strings.Split(parts[3], ",")}
+	if heartbeat, err := strconv.Atoi(parts[1]); err == nil {
+		result.HeartbeatTimeout = time.Duration(heartbeat) * time.Second
+	}
+	supported := false
+	for _, transport := range result.Transports {
+		if transport == "websocket" {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, errors.New(fmt.Sprintf("server does not support websocket transport: %s", parts[3]))
+	}
+	return result, nil
+}
+
+func websocketUrl(serverUrl string, sessionId string) (string, error) {
+	target, err := url.Parse(strings.TrimRight(serverUrl, "/"))
+	if err != nil {
+		return "", err
+	}
+	switch target.Scheme {
+	case "https":
+		target.Scheme = "wss"
+	case "http":
+		target.Scheme = "ws"
+	default:
+		return "", errors.New(fmt.Sprintf("unsupported server url scheme %s", target.Scheme))
+	}
+	target.Path += "/socket.io/1/websocket/" + sessionId
+	return target.String(), nil
+}
@@ -0,0 +1,32 @@
+package event
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	p, err := parsePacket("2::")
+	assert.Nil(t, err)
+	assert.Equal(t, packet{Type: packetHeartbeat}, p)
+	assert.Equal(t, "2::", p.String())
+
+	p, err = parsePacket(`5:1+::{"name":"ftrack.event","args":[{"topic":"a:b"}]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, packetEvent, p.Type)
+	assert.Equal(t, "1+", p.Id)
+	events, err := decodeEvents(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "a:b", events[0].Topic)
+
+	_, err = parsePacket("x::")
+	assert.NotNil(t, err)
+	_, err = parsePacket("5")
+	assert.NotNil(t, err)
+}
+
+func TestWebsocketUrl(t *testing.T) {
+	target, err := websocketUrl("https://example.ftrackapp.com/", "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, "wss://example.ftrackapp.com/socket.io/1/websocket/abc", target)
+}
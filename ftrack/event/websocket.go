@@ -0,0 +1,244 @@
+package event
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	opText  byte = 0x1
+	opClose byte = 0x8
+	opPing  byte = 0x9
+	opPong  byte = 0xA
+)
+
+const websocketGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds the size of a single websocket message.
+const maxMessageSize int64 = 32 << 20
+
+var errWebsocketClosed = errors.New("websocket closed")
+
+// websocketConn is a minimal RFC 6455 connection supporting the text messages
+// socket.io exchanges.
+type websocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+	// client connections mask their frames, server connections don't.
+	client bool
+}
+
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGuid))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func dialWebsocket(rawUrl string, header http.Header, timeout time.Duration) (*websocketConn, error) {
+	target, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch target.Scheme {
+	case "ws":
+		host := target.Host
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "80")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "wss":
+		host := target.Host
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "443")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: target.Hostname()})
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported websocket scheme %s", target.Scheme))
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        target,
+		Host:       target.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err := request.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		_ = conn.Close()
+		return nil, errors.New(fmt.Sprintf("websocket handshake failed: %s", response.Status))
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		_ = conn.Close()
+		return nil, errors.New("websocket handshake failed: invalid Sec-WebSocket-Accept")
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &websocketConn{conn: conn, reader: reader, client: true}, nil
+}
+
+func (ws *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	header := []byte{0x80 | opcode, 0}
+	length := len(payload)
+	switch {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	if ws.client {
+		header[1] |= 0x80
+		mask := make([]byte, 4)
+		if _, err := rand.Read(mask); err != nil {
+			return err
+		}
+		header = append(header, mask...)
+		masked := make([]byte, length)
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		payload = masked
+	}
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ws *websocketConn) WriteText(message string) error {
+	return ws.writeFrame(opText, []byte(message))
+}
+
+func (ws *websocketConn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(extended))
+	}
+	if length < 0 || length > maxMessageSize {
+		return false, 0, nil, errors.New(fmt.Sprintf("websocket frame of %d bytes exceeds limit", length))
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(ws.reader, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// ReadText returns the next text message, answering pings on the way.
+func (ws *websocketConn) ReadText() (string, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return "", err
+		}
+		switch opcode {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return "", err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = ws.writeFrame(opClose, nil)
+			return "", errWebsocketClosed
+		}
+		message = append(message, payload...)
+		if int64(len(message)) > maxMessageSize {
+			return "", errors.New("websocket message exceeds limit")
+		}
+		if fin {
+			return string(message), nil
+		}
+	}
+}
+
+func (ws *websocketConn) SetReadDeadline(deadline time.Time) error {
+	return ws.conn.SetReadDeadline(deadline)
+}
+
+func (ws *websocketConn) Close() error {
+	_ = ws.writeFrame(opClose, nil)
+	return ws.conn.Close()
+}
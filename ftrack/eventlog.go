@@ -0,0 +1,58 @@
+package ftrack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const queryDatetimeFormat string = "2006-01-02T15:04:05"
+
+// QueryEvents returns the Event entities stored by the server, oldest first.
+// The topic is matched against the event action and may contain * wildcards,
+// since is inclusive and until exclusive, zero times leave the range open.
+// Filter is an optional additional query condition.
+func (session *Session) QueryEvents(topic string, since time.Time, until time.Time, filter string) ([]map[string]interface{}, error) {
+	return session.QueryAll(session.eventsExpression(topic, since, until, filter), DefaultQueryPageSize)
+}
+
+// QueryEventPages is QueryEvents calling onPage with each page of events
+// instead of loading them all in memory.
+func (session *Session) QueryEventPages(topic string, since time.Time, until time.Time, filter string, onPage func(page []map[string]interface{}) error) error {
+	return session.QueryPages(session.eventsExpression(topic, since, until, filter), DefaultQueryPageSize, onPage)
+}
+
+func (session *Session) eventsExpression(topic string, since time.Time, until time.Time, filter string) string {
+	var conditions []string
+	if topic != "" {
+		if strings.Contains(topic, "*") {
+			conditions = append(conditions, fmt.Sprintf("action like %s", strconv.Quote(strings.Replace(topic, "*", "%", -1))))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("action is %s", strconv.Quote(topic)))
+		}
+	}
+	if !since.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("created_at >= \"%s\"", session.formatQueryTime(since)))
+	}
+	if !until.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("created_at < \"%s\"", session.formatQueryTime(until)))
+	}
+	if filter != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s)", filter))
+	}
+	expression := "select id, action, data, created_at, user_id, parent_id, parent_type, project_id from Event"
+	if len(conditions) > 0 {
+		expression += " where " + strings.Join(conditions, " and ")
+	}
+	return expression + " order by created_at"
+}
+
+func (session *Session) formatQueryTime(value time.Time) string {
+	if enabled, _ := session.ServerInformation["is_timezone_support_enabled"].(bool); enabled {
+		value = value.UTC()
+	} else {
+		value = value.Local()
+	}
+	return value.Format(queryDatetimeFormat)
+}
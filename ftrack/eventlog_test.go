@@ -0,0 +1,37 @@
+package ftrack
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSession_QueryEvents(t *testing.T) {
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{"action": "query", "data": []interface{}{
+			map[string]interface{}{EntityTypeKey: "Event", "id": "1", "action": "change.status.shot", "data": "{}"},
+		}}}
+	})
+	since := time.Date(2020, 5, 1, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	until := time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)
+	events, err := session.QueryEvents("change.status.*", since, until, `project_id is "p"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, events, 1)
+	assert.Equal(t,
+		`select id, action, data, created_at, user_id, parent_id, parent_type, project_id from Event`+
+			` where action like "change.status.%" and created_at >= "2020-05-01T08:00:00"`+
+			` and created_at < "2020-05-02T00:00:00" and (project_id is "p") order by created_at offset 0 limit 500`,
+		server.Batches()[0][0]["expression"],
+	)
+
+	assert.Equal(t,
+		`select id, action, data, created_at, user_id, parent_id, parent_type, project_id from Event where action is "update" order by created_at`,
+		session.eventsExpression("update", time.Time{}, time.Time{}, ""),
+	)
+	assert.Equal(t,
+		`select id, action, data, created_at, user_id, parent_id, parent_type, project_id from Event where action is "a\\\"b" order by created_at`,
+		session.eventsExpression(`a\"b`, time.Time{}, time.Time{}, ""),
+	)
+}
@@ -0,0 +1,217 @@
+package ftracktest
+
+import (
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/querylang"
+	"strings"
+)
+
+// storeEntity exposes an entity of the store to querylang, relations and
+// collections are resolved through their foreign keys. The store is locked
+// while it is used.
+type storeEntity struct {
+	store      *Store
+	entityType *entityType
+	data       map[string]interface{}
+}
+
+func (store *Store) entity(typeName string, data map[string]interface{}) *storeEntity {
+	return &storeEntity{store: store, entityType: store.types[typeName], data: data}
+}
+
+func (entity *storeEntity) Get(attribute string) (interface{}, bool) {
+	store := entity.store
+	if _, ok := entity.entityType.properties[attribute]; !ok {
+		return nil, false
+	}
+	target, isRelation := entity.entityType.target(attribute)
+	if !isRelation {
+		value, ok := entity.data[attribute]
+		return value, ok
+	}
+	foreignKey := store.foreignKey(entity.entityType.name, attribute)
+	if !entity.entityType.isCollection(attribute) {
+		id := entity.data[foreignKey]
+		if id == nil {
+			return nil, true
+		}
+		typeName, index := store.find(target, []string{fmt.Sprint(id)})
+		if index < 0 {
+			return nil, true
+		}
+		return store.entity(typeName, store.entities[typeName][index]), true
+	}
+	items := []querylang.Entity{}
+	if foreignKey == "" {
+		return items, true
+	}
+	for _, typeName := range store.subtypes(target) {
+		for _, related := range store.entities[typeName] {
+			if related[foreignKey] != nil && related[foreignKey] == entity.data["id"] {
+				items = append(items, store.entity(typeName, related))
+			}
+		}
+	}
+	return items, true
+}
+
+// storeSource is the querylang.Source of subqueries.
+type storeSource struct {
+	store *Store
+}
+
+func (source storeSource) Entities(entityType string) []querylang.Entity {
+	var entities []querylang.Entity
+	for _, typeName := range source.store.subtypes(entityType) {
+		for _, data := range source.store.entities[typeName] {
+			entities = append(entities, source.store.entity(typeName, data))
+		}
+	}
+	return entities
+}
+
+func queryError(format string, args ...interface{}) error {
+	return &serverError{exception: "ServerError", content: fmt.Sprintf(format, args...)}
+}
+
+// checkPath fails for paths through attributes the entity types do not have
+// and returns the type of the entities at the end of path, empty for scalar
+// attributes.
+func (store *Store) checkPath(name string, path string) (string, error) {
+	for _, attribute := range strings.Split(path, ".") {
+		if name == "" {
+			return "", queryError("Invalid attribute path %s.", path)
+		}
+		entityType, err := store.entityType(name)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := entityType.properties[attribute]; !ok {
+			return "", queryError("%s has no attribute %s.", name, attribute)
+		}
+		name, _ = entityType.target(attribute)
+	}
+	return name, nil
+}
+
+func (store *Store) checkExpression(name string, expression querylang.Expression) error {
+	switch casted := expression.(type) {
+	case *querylang.And:
+		for _, expression := range casted.Expressions {
+			if err := store.checkExpression(name, expression); err != nil {
+				return err
+			}
+		}
+	case *querylang.Or:
+		for _, expression := range casted.Expressions {
+			if err := store.checkExpression(name, expression); err != nil {
+				return err
+			}
+		}
+	case *querylang.Not:
+		return store.checkExpression(name, casted.Expression)
+	case *querylang.Relation:
+		target, err := store.checkPath(name, casted.Path)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			return queryError("%s.%s is not a relation.", name, casted.Path)
+		}
+		return store.checkExpression(target, casted.Expression)
+	case *querylang.Comparison:
+		_, err := store.checkPath(name, casted.Path)
+		return err
+	case *querylang.In:
+		if _, err := store.checkPath(name, casted.Path); err != nil {
+			return err
+		}
+		if casted.Subquery != nil {
+			return store.checkQuery(casted.Subquery)
+		}
+	}
+	return nil
+}
+
+func (store *Store) checkQuery(query *querylang.Query) error {
+	if _, err := store.entityType(query.EntityType); err != nil {
+		return err
+	}
+	var paths []string
+	paths = append(paths, query.Projections...)
+	for _, ordering := range query.OrderBy {
+		paths = append(paths, ordering.Path)
+	}
+	for _, path := range paths {
+		if _, err := store.checkPath(query.EntityType, path); err != nil {
+			return err
+		}
+	}
+	if query.Where != nil {
+		return store.checkExpression(query.EntityType, query.Where)
+	}
+	return nil
+}
+
+// project returns the primary key of entity with the attributes at paths,
+// related entities nested with their own primary key.
+func project(entity *storeEntity, paths []string) map[string]interface{} {
+	result := map[string]interface{}{EntityTypeKey: entity.entityType.name}
+	for _, attribute := range entity.entityType.primaryKey {
+		result[attribute] = entity.data[attribute]
+	}
+	nested := map[string][]string{}
+	var attributes []string
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if _, ok := nested[parts[0]]; !ok {
+			attributes = append(attributes, parts[0])
+			nested[parts[0]] = nil
+		}
+		if len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		}
+	}
+	for _, attribute := range attributes {
+		value, ok := entity.Get(attribute)
+		if !ok {
+			continue
+		}
+		switch casted := value.(type) {
+		case *storeEntity:
+			result[attribute] = project(casted, nested[attribute])
+		case []querylang.Entity:
+			items := []interface{}{}
+			for _, item := range casted {
+				items = append(items, project(item.(*storeEntity), nested[attribute]))
+			}
+			result[attribute] = items
+		default:
+			result[attribute] = value
+		}
+	}
+	return result
+}
+
+// query runs expression and returns the matching entities and the offset of
+// the next page, nil when there are no more.
+func (store *Store) query(expression string) ([]map[string]interface{}, interface{}, error) {
+	query, err := querylang.ParseQuery(expression)
+	if err != nil {
+		return nil, nil, queryError("%s", err)
+	}
+	if err := store.checkQuery(query); err != nil {
+		return nil, nil, err
+	}
+	source := storeSource{store: store}
+	matching := query.Filter(source.Entities(query.EntityType), source)
+	var next interface{}
+	if query.Limit >= 0 && query.Offset+query.Limit < len(matching) {
+		next = query.Offset + query.Limit
+	}
+	data := []map[string]interface{}{}
+	for _, entity := range query.Page(matching) {
+		data = append(data, project(entity.(*storeEntity), query.Projections))
+	}
+	return data, next, nil
+}
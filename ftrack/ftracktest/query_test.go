@@ -0,0 +1,87 @@
+package ftracktest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func mustAdd(t *testing.T, server *Server, entityType string, data map[string]interface{}) map[string]interface{} {
+	entity, err := server.Store.Add(entityType, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func TestServer_Query(t *testing.T) {
+	session, server := newSession(t)
+	done := server.Store.Entities("State")[3]
+	approved := mustAdd(t, server, "Status", map[string]interface{}{"name": "Approved", "state_id": done["id"]})
+	pending := mustAdd(t, server, "Status", map[string]interface{}{"name": "Pending", "state_id": server.Store.Entities("State")[0]["id"]})
+	project := mustAdd(t, server, "Project", map[string]interface{}{"name": "demo"})
+	shot := mustAdd(t, server, "Shot", map[string]interface{}{"name": "sh010", "parent_id": project["id"], "project_id": project["id"]})
+	compositing := mustAdd(t, server, "Task", map[string]interface{}{"name": "compositing", "parent_id": shot["id"], "status_id": approved["id"], "bid": 2.0})
+	mustAdd(t, server, "Task", map[string]interface{}{"name": "lighting", "parent_id": shot["id"], "status_id": pending["id"], "bid": 4.0})
+	asset := mustAdd(t, server, "Asset", map[string]interface{}{"name": "plate", "context_id": shot["id"]})
+	for _, version := range []float64{1, 2} {
+		mustAdd(t, server, "AssetVersion", map[string]interface{}{"version": version, "asset_id": asset["id"], "task_id": compositing["id"]})
+	}
+
+	for expression, expected := range map[string][]string{
+		`select name from Task where status.state.short is "DONE"`:                                      {"compositing"},
+		`select name from Task where status has (name like "pend%")`:                                    {"lighting"},
+		`select name from Task where parent.name is "sh010" and bid > 3`:                                {"lighting"},
+		`select name from Task where not bid >= 3 or name is "x"`:                                       {"compositing"},
+		`select name from TypedContext where children any (name is "lighting")`:                         {"sh010"},
+		`select name from TypedContext where assets any (versions any (version is 2))`:                  {"sh010"},
+		`select name from Task where id in (select task_id from AssetVersion where version > 1)`:        {"compositing"},
+		`select name from Task where status_id not_in (select id from Status where name is "Approved")`: {"lighting"},
+		`select name from Task where project.name is "demo"`:                                            {},
+		`select name from Task order by status.name desc`:                                               {"lighting", "compositing"},
+		`select name from TypedContext where name in ("sh010", "lighting") order by name`:               {"lighting", "sh010"},
+	} {
+		result, err := session.Query(expression)
+		if err != nil {
+			t.Fatalf("%s: %s", expression, err)
+		}
+		names := []string{}
+		for _, entity := range result.Data {
+			names = append(names, entity["name"].(string))
+		}
+		assert.Equal(t, expected, names, expression)
+	}
+
+	result, err := session.Query(`select name, status.name, status.state.short, parent.name from Task where name is "compositing"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := result.Data[0]
+	assert.Equal(t, "Approved", task["status"].(map[string]interface{})["name"])
+	state := task["status"].(map[string]interface{})["state"].(map[string]interface{})
+	assert.Equal(t, "State", state[EntityTypeKey])
+	assert.Equal(t, "DONE", state["short"])
+	assert.Equal(t, "sh010", task["parent"].(map[string]interface{})["name"])
+
+	result, err = session.Query(`select versions.version from Asset`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := result.Data[0]["versions"].([]interface{})
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "AssetVersion", versions[0].(map[string]interface{})[EntityTypeKey])
+	assert.NotNil(t, versions[0].(map[string]interface{})["version"])
+
+	for _, expression := range []string{
+		"select name from Task where",
+		"select unknown from Task",
+		"select status.unknown from Task",
+		"select name.first from Task",
+		"select name from Task where status has (unknown is 1)",
+		"select name from Task where name has (id is 1)",
+		"select name from Task where id in (select id from Unknown)",
+		"select name from Task order by unknown",
+	} {
+		_, err := session.Query(expression)
+		assert.NotNil(t, err, expression)
+	}
+}
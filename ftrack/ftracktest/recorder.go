@@ -0,0 +1,242 @@
+package ftracktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type RecorderMode int
+
+const (
+	// Replay serves the interactions of the golden file in order, without
+	// network.
+	Replay RecorderMode = iota
+	// Record sends the requests with Transport and keeps the interactions,
+	// written to the golden file by Save.
+	Record
+)
+
+// Redacted replaces the credentials in golden files.
+const Redacted = "REDACTED"
+
+var (
+	redactedHeaders    = []string{"ftrack-api-key", "Authorization", "Cookie", "Set-Cookie"}
+	redactedParameters = []string{"apiKey", "api_key", "api_user"}
+)
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Recorder is an http.RoundTripper capturing the requests of a session to a
+// golden file and serving them back. Credentials are redacted from the
+// recorded requests and responses.
+//
+// Replayed requests must come in the recorded order with the same method,
+// path, query and body, the host is ignored so any ServerUrl can be used.
+// JSON bodies are compared by value.
+type Recorder struct {
+	Path string
+	Mode RecorderMode
+	// Transport sends the recorded requests, http.DefaultTransport when nil.
+	Transport    http.RoundTripper
+	mu           sync.Mutex
+	interactions []Interaction
+	position     int
+}
+
+// NewRecorder returns a recorder for the golden file at path, loaded when
+// replaying.
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+	recorder := &Recorder{Path: path, Mode: mode}
+	if mode == Replay {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &recorder.interactions); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid golden file %s: %s", path, err))
+		}
+	}
+	return recorder, nil
+}
+
+// Client returns an http.Client sending its requests through the recorder,
+// to be set as SessionConfig.HttpClient.
+func (recorder *Recorder) Client() *http.Client {
+	return &http.Client{Transport: recorder}
+}
+
+// Interactions returns the interactions recorded or loaded.
+func (recorder *Recorder) Interactions() []Interaction {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]Interaction(nil), recorder.interactions...)
+}
+
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
+
+func redactUrl(requestUrl *url.URL) string {
+	redacted := *requestUrl
+	query := redacted.Query()
+	for _, name := range redactedParameters {
+		if query.Get(name) != "" {
+			query.Set(name, Redacted)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+// requestKey identifies a recorded request regardless of the server.
+func requestKey(method string, requestUrl string) string {
+	parsed, err := url.Parse(requestUrl)
+	if err != nil {
+		return method + " " + requestUrl
+	}
+	return method + " " + parsed.RequestURI()
+}
+
+// equalBodies compares request bodies, by value when both are JSON.
+func equalBodies(expected string, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	var expectedValue, actualValue interface{}
+	if json.Unmarshal([]byte(expected), &expectedValue) != nil || json.Unmarshal([]byte(actual), &actualValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(expectedValue, actualValue)
+}
+
+func (recorder *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded := RecordedRequest{
+		Method: request.Method,
+		Url:    redactUrl(request.URL),
+		Header: redactHeader(request.Header),
+		Body:   string(body),
+	}
+	if recorder.Mode == Record {
+		return recorder.record(request, recorded, body)
+	}
+	return recorder.replay(request, recorded)
+}
+
+func (recorder *Recorder) record(request *http.Request, recorded RecordedRequest, body []byte) (*http.Response, error) {
+	transport := recorder.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	forwarded := request.Clone(request.Context())
+	forwarded.Body = ioutil.NopCloser(bytes.NewReader(body))
+	response, err := transport.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorder.mu.Lock()
+	recorder.interactions = append(recorder.interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     redactHeader(response.Header),
+			Body:       string(content),
+		},
+	})
+	recorder.mu.Unlock()
+	response.Body = ioutil.NopCloser(bytes.NewReader(content))
+	return response, nil
+}
+
+func (recorder *Recorder) replay(request *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	key := requestKey(recorded.Method, recorded.Url)
+	if recorder.position >= len(recorder.interactions) {
+		return nil, errors.New(fmt.Sprintf("no recorded interaction left in %s for %s", recorder.Path, key))
+	}
+	interaction := recorder.interactions[recorder.position]
+	if expected := requestKey(interaction.Request.Method, interaction.Request.Url); expected != key {
+		return nil, errors.New(fmt.Sprintf("interaction %d in %s is %s, got %s", recorder.position, recorder.Path, expected, key))
+	}
+	if !equalBodies(interaction.Request.Body, recorded.Body) {
+		return nil, errors.New(fmt.Sprintf(
+			"interaction %d in %s is %s with body %s, got body %s",
+			recorder.position, recorder.Path, key, interaction.Request.Body, recorded.Body,
+		))
+	}
+	recorder.position++
+	response := interaction.Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       request,
+	}, nil
+}
+
+// Save writes the recorded interactions to the golden file, it does nothing
+// when replaying.
+func (recorder *Recorder) Save() error {
+	if recorder.Mode != Record {
+		return nil
+	}
+	content, err := json.MarshalIndent(recorder.Interactions(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(recorder.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(recorder.Path, append(content, '\n'), 0644)
+}
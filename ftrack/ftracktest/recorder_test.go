@@ -0,0 +1,122 @@
+package ftracktest
+
+import (
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ftracktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "session.json")
+
+	server, err := NewServer(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewRecorder(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ftrack.NewSession(ftrack.SessionConfig{
+		ServerUrl:  server.URL,
+		ApiUser:    server.ApiUser,
+		ApiKey:     server.ApiKey,
+		HttpClient: recorder.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := session.Query("select username from User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/component/get?id=missing&username="+server.ApiUser+"&apiKey="+server.ApiKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := recorder.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Nil(t, recorder.Save())
+	server.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, strings.Contains(string(content), server.ApiKey))
+	assert.Len(t, recorder.Interactions(), 3)
+	assert.Equal(t, Redacted, recorder.Interactions()[0].Request.Header.Get("ftrack-api-key"))
+	assert.Contains(t, recorder.Interactions()[2].Request.Url, "apiKey="+Redacted)
+
+	replayer, err := NewRecorder(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = ftrack.NewSession(ftrack.SessionConfig{
+		ServerUrl:  "http://replay.invalid",
+		ApiUser:    server.ApiUser,
+		ApiKey:     "any",
+		HttpClient: replayer.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ServerVersion, session.ServerVersion)
+	// Requests must send the recorded operations.
+	_, err = session.Query("select id from User")
+	assert.NotNil(t, err)
+	replayed, err := session.Query("select username from User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, recorded.Data, replayed.Data)
+
+	// Requests must come in the recorded order.
+	request, err = http.NewRequest(http.MethodGet, "http://replay.invalid/component/get?id=other&username="+DefaultApiUser+"&apiKey=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayer.Client().Do(request)
+	assert.NotNil(t, err)
+	request, err = http.NewRequest(http.MethodGet, "http://replay.invalid/component/get?id=missing&username="+DefaultApiUser+"&apiKey=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err = replayer.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	_, err = session.Query("select username from User")
+	assert.NotNil(t, err)
+
+	_, err = NewRecorder(filepath.Join(dir, "missing.json"), Replay)
+	assert.NotNil(t, err)
+}
+
+func TestRecorder_RedactsEventHubCredentials(t *testing.T) {
+	handshake, err := url.Parse("https://example.ftrackapp.com/socket.io/1/?api_user=john&api_key=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := redactUrl(handshake)
+	assert.False(t, strings.Contains(redacted, "secret"))
+	assert.False(t, strings.Contains(redacted, "john"))
+	assert.Equal(t, "https://example.ftrackapp.com/socket.io/1/?api_key="+Redacted+"&api_user="+Redacted, redacted)
+}
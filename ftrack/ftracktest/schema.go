@@ -0,0 +1,163 @@
+package ftracktest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+// entityFixture describes an entity type of the default schemas. Attributes
+// are space separated name:kind pairs where kind is one of string, integer,
+// number, boolean, datetime, an entity type for a relation or []Type for a
+// collection.
+type entityFixture struct {
+	name       string
+	aliasFor   string
+	primaryKey []string
+	attributes string
+}
+
+const contextAttributes = "id:string name:string description:string parent_id:string parent:Context " +
+	"project_id:string project:Project status_id:string status:Status type_id:string type:Type " +
+	"priority_id:string priority:Priority object_type_id:string object_type:ObjectType " +
+	"thumbnail_id:string bid:number sort:number start_date:datetime end_date:datetime created_at:datetime " +
+	"children:[]TypedContext assets:[]Asset notes:[]Note metadata:[]Metadata"
+
+const componentAttributes = "id:string name:string file_type:string size:integer system_type:string " +
+	"version_id:string version:AssetVersion container_id:string container:ContainerComponent " +
+	"component_locations:[]ComponentLocation metadata:[]Metadata"
+
+var entityFixtures = []entityFixture{
+	{name: "User", attributes: "id:string username:string first_name:string last_name:string email:string is_active:boolean thumbnail_id:string"},
+	{name: "State", attributes: "id:string name:string short:string"},
+	{name: "Status", attributes: "id:string name:string color:string sort:integer state_id:string state:State"},
+	{name: "Priority", attributes: "id:string name:string color:string value:number sort:integer"},
+	{name: "Type", attributes: "id:string name:string color:string sort:integer"},
+	{name: "ObjectType", attributes: "id:string name:string sort:integer"},
+	{name: "Context", attributes: "id:string name:string children:[]TypedContext notes:[]Note metadata:[]Metadata"},
+	{name: "Project", attributes: "id:string name:string full_name:string status:string root:string thumbnail_id:string " +
+		"start_date:datetime end_date:datetime created_at:datetime children:[]TypedContext notes:[]Note metadata:[]Metadata"},
+	{name: "TypedContext", attributes: contextAttributes},
+	{name: "Task", aliasFor: "TypedContext", attributes: contextAttributes + " timelogs:[]Timelog"},
+	{name: "Shot", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "Sequence", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "Episode", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "Folder", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "Milestone", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "AssetBuild", aliasFor: "TypedContext", attributes: contextAttributes},
+	{name: "AssetType", attributes: "id:string name:string short:string"},
+	{name: "Asset", attributes: "id:string name:string context_id:string parent:Context type_id:string type:AssetType versions:[]AssetVersion metadata:[]Metadata"},
+	{name: "AssetVersion", attributes: "id:string version:integer comment:string asset_id:string asset:Asset task_id:string task:Task " +
+		"status_id:string status:Status user_id:string user:User date:datetime is_published:boolean thumbnail_id:string " +
+		"components:[]Component notes:[]Note metadata:[]Metadata"},
+	{name: "Component", attributes: componentAttributes},
+	{name: "FileComponent", aliasFor: "Component", attributes: componentAttributes},
+	{name: "ContainerComponent", aliasFor: "Component", attributes: componentAttributes + " members:[]Component"},
+	{name: "SequenceComponent", aliasFor: "Component", attributes: componentAttributes + " padding:integer members:[]Component"},
+	{name: "Location", attributes: "id:string name:string label:string description:string"},
+	{name: "ComponentLocation", attributes: "id:string component_id:string component:Component location_id:string location:Location resource_identifier:string"},
+	{name: "Note", attributes: "id:string content:string parent_id:string parent_type:string user_id:string author:User date:datetime category_id:string"},
+	{name: "Metadata", primaryKey: []string{"parent_id", "key"}, attributes: "parent_id:string parent_type:string key:string value:string"},
+	{name: "Timelog", attributes: "id:string context_id:string user_id:string user:User start:datetime duration:number comment:string"},
+	{name: "Job", attributes: "id:string type:string status:string data:string user_id:string user:User created_at:datetime finished_at:datetime"},
+	{name: "Event", attributes: "id:string action:string data:string created_at:datetime user_id:string parent_id:string parent_type:string project_id:string insert:string"},
+}
+
+// foreignKeys names the attribute relating the entities of the relations
+// whose foreign key cannot be derived from their name. For collections it is
+// an attribute of the related entities.
+var foreignKeys = map[string]string{
+	"Context.children":           "parent_id",
+	"Context.notes":              "parent_id",
+	"Context.metadata":           "parent_id",
+	"TypedContext.parent":        "parent_id",
+	"TypedContext.children":      "parent_id",
+	"TypedContext.assets":        "context_id",
+	"TypedContext.notes":         "parent_id",
+	"TypedContext.metadata":      "parent_id",
+	"Task.timelogs":              "context_id",
+	"Project.children":           "parent_id",
+	"Project.notes":              "parent_id",
+	"Project.metadata":           "parent_id",
+	"Asset.parent":               "context_id",
+	"Asset.metadata":             "parent_id",
+	"AssetVersion.notes":         "parent_id",
+	"AssetVersion.metadata":      "parent_id",
+	"Component.metadata":         "parent_id",
+	"ContainerComponent.members": "container_id",
+	"SequenceComponent.members":  "container_id",
+	"Note.author":                "user_id",
+}
+
+func attributeSchema(kind string) map[string]interface{} {
+	switch kind {
+	case "string", "integer", "number", "boolean":
+		return map[string]interface{}{"type": kind}
+	case "datetime":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if strings.HasPrefix(kind, "[]") {
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": strings.TrimPrefix(kind, "[]")}}
+	}
+	return map[string]interface{}{"$ref": kind}
+}
+
+func (fixture entityFixture) schema() map[string]interface{} {
+	primaryKey := fixture.primaryKey
+	if primaryKey == nil {
+		primaryKey = []string{"id"}
+	}
+	properties := map[string]interface{}{}
+	var projections []string
+	for _, attribute := range strings.Fields(fixture.attributes) {
+		parts := strings.SplitN(attribute, ":", 2)
+		properties[parts[0]] = attributeSchema(parts[1])
+		if _, ok := properties[parts[0]].(map[string]interface{})["type"]; ok && !strings.HasPrefix(parts[1], "[]") {
+			projections = append(projections, parts[0])
+		}
+	}
+	schema := map[string]interface{}{
+		"id":                  fixture.name,
+		"type":                "object",
+		"primary_key":         primaryKey,
+		"required":            primaryKey,
+		"immutable":           primaryKey,
+		"computed":            []string{},
+		"properties":          properties,
+		"default_projections": projections,
+		"system_projections":  primaryKey,
+	}
+	if fixture.aliasFor != "" {
+		schema["alias_for"] = map[string]interface{}{"id": fixture.aliasFor}
+	}
+	return schema
+}
+
+// DefaultSchemas returns the schemas served by default, a subset of the
+// entity types of an ftrack server.
+func DefaultSchemas() []map[string]interface{} {
+	var schemas []map[string]interface{}
+	for _, fixture := range entityFixtures {
+		// Round trip through JSON so the schemas look like the ones decoded
+		// from a response.
+		encoded, _ := json.Marshal(fixture.schema())
+		var schema map[string]interface{}
+		_ = json.Unmarshal(encoded, &schema)
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+// LoadSchemas reads schemas saved from the query_schemas response of a
+// server, such as the JSON encoded Session.Schemas.
+func LoadSchemas(path string) ([]map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []map[string]interface{}
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
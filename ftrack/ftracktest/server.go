@@ -0,0 +1,297 @@
+// Package ftracktest provides an in-memory ftrack server for tests.
+package ftracktest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultApiUser = "ftracktest"
+	DefaultApiKey  = "ftracktest-api-key"
+	ServerVersion  = "4.13.0"
+	ApiEndpoint    = "/api"
+	// ServerLocationId is the id of the ftrack.server location.
+	ServerLocationId    = "3a372bde-05bc-11e4-8908-20c9d081909b"
+	UnmanagedLocationId = "cb268ecc-8809-11e3-a7e2-20c9d081909b"
+)
+
+type serverError struct {
+	exception string
+	content   string
+}
+
+func (error *serverError) Error() string {
+	return fmt.Sprintf("%s: %s", error.exception, error.content)
+}
+
+type Options struct {
+	ApiUser *string
+	ApiKey  *string
+	// Schemas served and used to validate the entities, DefaultSchemas when
+	// nil.
+	Schemas []map[string]interface{}
+	// ServerInformation is merged into the query_server_information result.
+	ServerInformation map[string]interface{}
+}
+
+func (options *Options) setDefaults() {
+	if options.ApiUser == nil {
+		apiUser := DefaultApiUser
+		options.ApiUser = &apiUser
+	}
+	if options.ApiKey == nil {
+		apiKey := DefaultApiKey
+		options.ApiKey = &apiKey
+	}
+	if options.Schemas == nil {
+		options.Schemas = DefaultSchemas()
+	}
+}
+
+// Server speaks the JSON protocol of the ftrack /api endpoint over an
+// httptest.Server. It answers query_server_information, query_schemas, query,
+// create, update, delete, get_signed_url and get_upload_metadata operations
+// from Store, a batch failing as a whole like on a real server. Uploaded
+// component data is kept in memory and served from /component/get.
+type Server struct {
+	*httptest.Server
+	ApiUser     string
+	ApiKey      string
+	Store       *Store
+	information map[string]interface{}
+	mu          sync.Mutex
+	batches     [][]map[string]interface{}
+	files       map[string][]byte
+}
+
+// NewServer starts a server seeded with a user for the api user, the server
+// and unmanaged locations and the task states.
+func NewServer(options Options) (*Server, error) {
+	options.setDefaults()
+	store, err := NewStore(options.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		ApiUser: *options.ApiUser,
+		ApiKey:  *options.ApiKey,
+		Store:   store,
+		information: map[string]interface{}{
+			"version":                     ServerVersion,
+			"is_timezone_support_enabled": true,
+		},
+		files: map[string][]byte{},
+	}
+	for key, value := range options.ServerInformation {
+		server.information[key] = value
+	}
+	if err := server.seed(); err != nil {
+		return nil, err
+	}
+	server.Server = httptest.NewServer(server)
+	return server, nil
+}
+
+func (server *Server) seed() error {
+	seeds := []struct {
+		entityType string
+		data       map[string]interface{}
+	}{
+		{"User", map[string]interface{}{"username": server.ApiUser, "is_active": true}},
+		{"Location", map[string]interface{}{"id": ServerLocationId, "name": "ftrack.server"}},
+		{"Location", map[string]interface{}{"id": UnmanagedLocationId, "name": "ftrack.unmanaged"}},
+		{"State", map[string]interface{}{"name": "Not started", "short": "NOT_STARTED"}},
+		{"State", map[string]interface{}{"name": "In progress", "short": "IN_PROGRESS"}},
+		{"State", map[string]interface{}{"name": "Blocked", "short": "BLOCKED"}},
+		{"State", map[string]interface{}{"name": "Done", "short": "DONE"}},
+	}
+	for _, seed := range seeds {
+		if _, ok := server.Store.types[seed.entityType]; !ok {
+			continue
+		}
+		if _, err := server.Store.Add(seed.entityType, seed.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Batches returns the operations received by the api endpoint, one slice per
+// request.
+func (server *Server) Batches() [][]map[string]interface{} {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([][]map[string]interface{}(nil), server.batches...)
+}
+
+// File returns the data uploaded for a component.
+func (server *Server) File(componentId string) ([]byte, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	content, ok := server.files[componentId]
+	return content, ok
+}
+
+func (server *Server) authenticated(apiUser string, apiKey string) bool {
+	return apiUser == server.ApiUser && apiKey == server.ApiKey
+}
+
+// signature signs the component id of signed urls with the api key.
+func (server *Server) signature(componentId string) string {
+	mac := hmac.New(sha256.New, []byte(server.ApiKey))
+	mac.Write([]byte(componentId))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == ApiEndpoint && r.Method == http.MethodPost:
+		server.serveApi(w, r)
+	case strings.HasPrefix(r.URL.Path, "/upload/") && r.Method == http.MethodPut:
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		componentId := strings.TrimPrefix(r.URL.Path, "/upload/")
+		server.mu.Lock()
+		server.files[componentId] = content
+		server.mu.Unlock()
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, componentId))
+	case r.URL.Path == "/component/get" && r.Method == http.MethodGet:
+		query := r.URL.Query()
+		signed := hmac.Equal([]byte(query.Get("signature")), []byte(server.signature(query.Get("id"))))
+		if !signed && !server.authenticated(query.Get("username"), query.Get("apiKey")) &&
+			!server.authenticated(r.Header.Get("ftrack-user"), r.Header.Get("ftrack-api-key")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		content, ok := server.File(query.Get("id"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	response := map[string]interface{}{"exception": "ServerError", "content": err.Error()}
+	if serverError, ok := err.(*serverError); ok {
+		response["exception"] = serverError.exception
+		response["content"] = serverError.content
+	}
+	writeJSON(w, response)
+}
+
+func (server *Server) serveApi(w http.ResponseWriter, r *http.Request) {
+	if !server.authenticated(r.Header.Get("ftrack-user"), r.Header.Get("ftrack-api-key")) {
+		writeError(w, &serverError{exception: "ServerError", content: "The supplied API key is not valid."})
+		return
+	}
+	var operations []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		writeError(w, &serverError{exception: "ServerError", content: fmt.Sprintf("Invalid request body: %s", err)})
+		return
+	}
+	server.mu.Lock()
+	server.batches = append(server.batches, operations)
+	server.mu.Unlock()
+
+	store := server.Store
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	snapshot := store.snapshot()
+	results := []interface{}{}
+	for _, operation := range operations {
+		result, err := server.handle(operation)
+		if err != nil {
+			store.restore(snapshot)
+			writeError(w, err)
+			return
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, results)
+}
+
+func stringSlice(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var values []string
+	for _, item := range items {
+		values = append(values, fmt.Sprint(item))
+	}
+	return values
+}
+
+// handle runs a single operation, the store is locked by the caller.
+func (server *Server) handle(operation map[string]interface{}) (interface{}, error) {
+	store := server.Store
+	action, _ := operation["action"].(string)
+	entityType, _ := operation["entity_type"].(string)
+	entityData, _ := operation["entity_data"].(map[string]interface{})
+	switch action {
+	case "query_server_information":
+		return server.information, nil
+	case "query_schemas":
+		return store.Schemas(), nil
+	case "query":
+		expression, _ := operation["expression"].(string)
+		data, next, err := store.query(expression)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"action":   action,
+			"data":     data,
+			"metadata": map[string]interface{}{"next": map[string]interface{}{"offset": next}},
+		}, nil
+	case "create":
+		data, err := store.create(entityType, entityData)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"action": action, "data": data}, nil
+	case "update":
+		data, err := store.update(entityType, stringSlice(operation["entity_key"]), entityData)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"action": action, "data": data}, nil
+	case "delete":
+		if err := store.delete(entityType, stringSlice(operation["entity_key"])); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"action": action, "data": true}, nil
+	case "get_signed_url":
+		componentId, _ := operation["component_id"].(string)
+		return map[string]interface{}{
+			"signed_url": fmt.Sprintf("%s/component/get?id=%s&signature=%s", server.URL, componentId, server.signature(componentId)),
+		}, nil
+	case "get_upload_metadata":
+		componentId, _ := operation["component_id"].(string)
+		return map[string]interface{}{
+			"url":     fmt.Sprintf("%s/upload/%s", server.URL, componentId),
+			"headers": map[string]string{"Content-Type": "application/octet-stream"},
+		}, nil
+	}
+	return nil, &serverError{exception: "ServerError", content: fmt.Sprintf("Unsupported action %q.", action)}
+}
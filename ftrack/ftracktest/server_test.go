@@ -0,0 +1,163 @@
+package ftracktest
+
+import (
+	"bytes"
+	"context"
+	"github.com/conducte/ftrack-golang-api/ftrack"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newSession(t *testing.T) (*ftrack.Session, *Server) {
+	server, err := NewServer(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	session, err := ftrack.NewSession(ftrack.SessionConfig{
+		ServerUrl: server.URL,
+		ApiUser:   server.ApiUser,
+		ApiKey:    server.ApiKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, server
+}
+
+func TestNewServer(t *testing.T) {
+	session, server := newSession(t)
+	assert.True(t, session.Initialized)
+	assert.Equal(t, ServerVersion, session.ServerVersion)
+	assert.Equal(t, []string{"id"}, session.GetPrimaryKeyAttributes("Task"))
+	assert.Equal(t, []string{"parent_id", "key"}, session.GetPrimaryKeyAttributes("Metadata"))
+	assert.NotNil(t, session.GetSchema("User"))
+
+	users, err := session.Query("select username from User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, users.Data, 1)
+	assert.Equal(t, server.ApiUser, users.Data[0]["username"])
+
+	_, err = ftrack.NewSession(ftrack.SessionConfig{ServerUrl: server.URL, ApiUser: server.ApiUser, ApiKey: "invalid"})
+	assert.IsType(t, &ftrack.ServerError{}, err)
+}
+
+func TestServer_CreateUpdateDelete(t *testing.T) {
+	session, server := newSession(t)
+	status, err := session.Create("Status", map[string]interface{}{"name": "Approved"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusId := status.Data["id"].(string)
+	task, err := session.Create("Task", map[string]interface{}{"name": "compositing", "status_id": statusId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Task", task.Data[ftrack.EntityTypeKey])
+	taskId := task.Data["id"].(string)
+
+	update, err := session.Update("Task", []string{taskId}, map[string]interface{}{"name": "lighting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "lighting", update.Data["name"])
+	assert.Equal(t, "lighting", server.Store.Get("Task", taskId)["name"])
+
+	query, err := session.Query("select name, status from TypedContext where id is " + taskId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, query.Data, 1)
+	assert.Equal(t, "Task", query.Data[0][ftrack.EntityTypeKey])
+	assert.Equal(t, statusId, query.Data[0]["status"].(map[string]interface{})["id"])
+
+	populated, err := session.EnsurePopulated(map[string]interface{}{ftrack.EntityTypeKey: "Task", "id": taskId}, []string{"name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "lighting", populated["name"])
+
+	deleted, err := session.Delete("Task", []string{taskId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, deleted.Data)
+	assert.Nil(t, server.Store.Get("Task", taskId))
+
+	_, err = session.Update("Task", []string{taskId}, map[string]interface{}{"name": "gone"})
+	assert.NotNil(t, err)
+	_, err = session.Create("Task", map[string]interface{}{"unknown": true})
+	assert.IsType(t, &ftrack.ServerValidationError{}, err)
+	_, err = session.Query("select name from Unknown")
+	assert.NotNil(t, err)
+}
+
+func TestServer_BatchRollback(t *testing.T) {
+	session, server := newSession(t)
+	_, err := session.Call(
+		ftrack.NewCreateOperation("Project", map[string]interface{}{"name": "rolled back"}),
+		ftrack.NewDeleteOperation("Project", []string{"missing"}),
+	)
+	assert.NotNil(t, err)
+	assert.Empty(t, server.Store.Entities("Project"))
+	assert.Len(t, server.Batches()[len(server.Batches())-1], 2)
+}
+
+func TestServer_QueryPages(t *testing.T) {
+	session, server := newSession(t)
+	for i := 0; i < 7; i++ {
+		_, err := server.Store.Add("Shot", map[string]interface{}{"name": string(rune('g' - i)), "sort": float64(i)})
+		assert.Nil(t, err)
+	}
+	_, err := server.Store.Add("Task", map[string]interface{}{"name": "a"})
+	assert.Nil(t, err)
+	shots, err := session.QueryAll("select name from Shot order by name", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, shots, 7)
+	assert.Equal(t, "a", shots[0]["name"])
+	assert.Equal(t, "g", shots[6]["name"])
+
+	contexts, err := session.Query("select name from TypedContext order by sort desc limit 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, contexts.Data, 2)
+	assert.Equal(t, "a", contexts.Data[0]["name"])
+}
+
+func TestServer_CreateComponent(t *testing.T) {
+	session, server := newSession(t)
+	content := []byte("frame")
+	dir, err := ioutil.TempDir("", "ftracktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "frame.exr")
+	assert.Nil(t, ioutil.WriteFile(path, content, 0644))
+
+	created, err := session.CreateComponent(path, ftrack.CreateComponentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	componentId := created[0].Data["id"].(string)
+	uploaded, ok := server.File(componentId)
+	assert.True(t, ok)
+	assert.Equal(t, content, uploaded)
+	assert.Equal(t, ServerLocationId, server.Store.Get("ComponentLocation", created[1].Data["id"].(string))["location_id"])
+
+	var downloaded bytes.Buffer
+	_, err = session.DownloadComponent(context.Background(), uuid.FromStringOrNil(componentId), &downloaded, ftrack.DownloadComponentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, downloaded.Bytes())
+}
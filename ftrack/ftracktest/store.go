@@ -0,0 +1,370 @@
+package ftracktest
+
+import (
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const EntityTypeKey = "__entity_type__"
+
+type entityType struct {
+	name       string
+	aliasFor   string
+	primaryKey []string
+	properties map[string]map[string]interface{}
+	schema     map[string]interface{}
+}
+
+func newEntityType(schema map[string]interface{}) (*entityType, error) {
+	name, ok := schema["id"].(string)
+	if !ok || name == "" {
+		return nil, errors.New(fmt.Sprintf("schema without id: %v", schema))
+	}
+	entityType := &entityType{name: name, schema: schema, properties: map[string]map[string]interface{}{}}
+	keys, _ := schema["primary_key"].([]interface{})
+	for _, key := range keys {
+		if key, ok := key.(string); ok {
+			entityType.primaryKey = append(entityType.primaryKey, key)
+		}
+	}
+	if len(entityType.primaryKey) == 0 {
+		return nil, errors.New(fmt.Sprintf("schema %s has no primary key", name))
+	}
+	if aliasFor, ok := schema["alias_for"].(map[string]interface{}); ok {
+		entityType.aliasFor, _ = aliasFor["id"].(string)
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for attribute, property := range properties {
+		if property, ok := property.(map[string]interface{}); ok {
+			entityType.properties[attribute] = property
+		}
+	}
+	return entityType, nil
+}
+
+// target returns the entity type of a relation or collection attribute.
+func (entityType *entityType) target(attribute string) (string, bool) {
+	property, ok := entityType.properties[attribute]
+	if !ok {
+		return "", false
+	}
+	if items, ok := property["items"].(map[string]interface{}); ok {
+		target, ok := items["$ref"].(string)
+		return target, ok
+	}
+	target, ok := property["$ref"].(string)
+	return target, ok
+}
+
+func (entityType *entityType) isCollection(attribute string) bool {
+	_, ok := entityType.properties[attribute]["items"]
+	return ok
+}
+
+// Store holds the entities of a Server. Entities are kept with their scalar
+// attributes, relations are resolved through their foreign keys.
+type Store struct {
+	mu       sync.Mutex
+	types    map[string]*entityType
+	order    []string
+	entities map[string][]map[string]interface{}
+}
+
+func NewStore(schemas []map[string]interface{}) (*Store, error) {
+	store := &Store{types: map[string]*entityType{}, entities: map[string][]map[string]interface{}{}}
+	for _, schema := range schemas {
+		entityType, err := newEntityType(schema)
+		if err != nil {
+			return nil, err
+		}
+		store.types[entityType.name] = entityType
+		store.order = append(store.order, entityType.name)
+	}
+	return store, nil
+}
+
+// Schemas returns the schemas of the entity types of the store.
+func (store *Store) Schemas() []map[string]interface{} {
+	var schemas []map[string]interface{}
+	for _, name := range store.order {
+		schemas = append(schemas, store.types[name].schema)
+	}
+	return schemas
+}
+
+// base returns the type name inherits from, Project and TypedContext are both
+// contexts.
+func (store *Store) base(name string) string {
+	if entityType, ok := store.types[name]; ok && entityType.aliasFor != "" {
+		return entityType.aliasFor
+	}
+	if name == "Project" || name == "TypedContext" {
+		return "Context"
+	}
+	return ""
+}
+
+func (store *Store) isA(name string, base string) bool {
+	for ; name != ""; name = store.base(name) {
+		if name == base {
+			return true
+		}
+	}
+	return false
+}
+
+// subtypes returns the types whose entities are returned when querying name.
+func (store *Store) subtypes(name string) []string {
+	var subtypes []string
+	for _, candidate := range store.order {
+		if store.isA(candidate, name) {
+			subtypes = append(subtypes, candidate)
+		}
+	}
+	return subtypes
+}
+
+func snakeCase(name string) string {
+	var result strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				result.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+// foreignKey returns the attribute relating the entities of a relation, an
+// attribute of the related entities for collections.
+func (store *Store) foreignKey(name string, attribute string) string {
+	for typeName := name; typeName != ""; typeName = store.base(typeName) {
+		if key, ok := foreignKeys[typeName+"."+attribute]; ok {
+			return key
+		}
+	}
+	entityType := store.types[name]
+	if !entityType.isCollection(attribute) {
+		return attribute + "_id"
+	}
+	target, _ := entityType.target(attribute)
+	for typeName := name; typeName != ""; typeName = store.base(typeName) {
+		key := snakeCase(typeName) + "_id"
+		if targetType, ok := store.types[target]; ok {
+			if _, ok := targetType.properties[key]; ok {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+func (store *Store) entityType(name string) (*entityType, error) {
+	entityType, ok := store.types[name]
+	if !ok {
+		return nil, &serverError{exception: "ServerError", content: fmt.Sprintf("Unknown entity type %s.", name)}
+	}
+	return entityType, nil
+}
+
+func entityKey(entityType *entityType, entity map[string]interface{}) []string {
+	var key []string
+	for _, attribute := range entityType.primaryKey {
+		key = append(key, fmt.Sprint(entity[attribute]))
+	}
+	return key
+}
+
+func sameKey(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the type and the index of the entity with key among the
+// entities of name and its subtypes.
+func (store *Store) find(name string, key []string) (string, int) {
+	for _, typeName := range store.subtypes(name) {
+		entityType := store.types[typeName]
+		for i, entity := range store.entities[typeName] {
+			if sameKey(entityKey(entityType, entity), key) {
+				return typeName, i
+			}
+		}
+	}
+	return "", -1
+}
+
+func copyEntity(name string, entity map[string]interface{}) map[string]interface{} {
+	copied := map[string]interface{}{EntityTypeKey: name}
+	for attribute, value := range entity {
+		copied[attribute] = value
+	}
+	return copied
+}
+
+// set validates and assigns data to entity. Relations may be set with the
+// related entity, its foreign key is set instead.
+func (store *Store) set(entityType *entityType, entity map[string]interface{}, data map[string]interface{}) error {
+	for attribute, value := range data {
+		if attribute == EntityTypeKey {
+			continue
+		}
+		if _, ok := entityType.properties[attribute]; !ok {
+			return &serverError{exception: "ValidationError", content: fmt.Sprintf("%s has no attribute %s.", entityType.name, attribute)}
+		}
+		if _, ok := entityType.target(attribute); ok {
+			if entityType.isCollection(attribute) {
+				return &serverError{exception: "ValidationError", content: fmt.Sprintf("Collection %s.%s cannot be set directly.", entityType.name, attribute)}
+			}
+			related, ok := value.(map[string]interface{})
+			foreignKey := store.foreignKey(entityType.name, attribute)
+			if value != nil && !ok || foreignKey == "" {
+				return &serverError{exception: "ValidationError", content: fmt.Sprintf("Invalid value for relation %s.%s.", entityType.name, attribute)}
+			}
+			if related == nil {
+				entity[foreignKey] = nil
+			} else {
+				entity[foreignKey] = related["id"]
+			}
+			continue
+		}
+		entity[attribute] = value
+	}
+	return nil
+}
+
+func (store *Store) create(name string, data map[string]interface{}) (map[string]interface{}, error) {
+	entityType, err := store.entityType(name)
+	if err != nil {
+		return nil, err
+	}
+	entity := map[string]interface{}{}
+	if err := store.set(entityType, entity, data); err != nil {
+		return nil, err
+	}
+	for _, attribute := range entityType.primaryKey {
+		if entity[attribute] != nil {
+			continue
+		}
+		if attribute != "id" {
+			return nil, &serverError{exception: "ValidationError", content: fmt.Sprintf("Missing primary key %s of %s.", attribute, name)}
+		}
+		entity[attribute] = uuid.Must(uuid.NewV4(), nil).String()
+	}
+	if _, index := store.find(name, entityKey(entityType, entity)); index >= 0 {
+		return nil, &serverError{exception: "ServerError", content: fmt.Sprintf("Duplicate entry %s %v.", name, entityKey(entityType, entity))}
+	}
+	store.entities[name] = append(store.entities[name], entity)
+	return copyEntity(name, entity), nil
+}
+
+func (store *Store) update(name string, key []string, data map[string]interface{}) (map[string]interface{}, error) {
+	if _, err := store.entityType(name); err != nil {
+		return nil, err
+	}
+	typeName, index := store.find(name, key)
+	if index < 0 {
+		return nil, &serverError{exception: "ServerError", content: fmt.Sprintf("Entity %s %v not found.", name, key)}
+	}
+	entityType := store.types[typeName]
+	entity := copyEntity(typeName, store.entities[typeName][index])
+	delete(entity, EntityTypeKey)
+	if err := store.set(entityType, entity, data); err != nil {
+		return nil, err
+	}
+	if !sameKey(entityKey(entityType, entity), key) {
+		return nil, &serverError{exception: "ValidationError", content: fmt.Sprintf("Primary key of %s %v is immutable.", name, key)}
+	}
+	store.entities[typeName][index] = entity
+	return copyEntity(typeName, entity), nil
+}
+
+func (store *Store) delete(name string, key []string) error {
+	if _, err := store.entityType(name); err != nil {
+		return err
+	}
+	typeName, index := store.find(name, key)
+	if index < 0 {
+		return &serverError{exception: "ServerError", content: fmt.Sprintf("Entity %s %v not found.", name, key)}
+	}
+	entities := store.entities[typeName]
+	store.entities[typeName] = append(entities[:index:index], entities[index+1:]...)
+	return nil
+}
+
+// snapshot returns a copy of the entities restoring the store with restore
+// when a batch fails.
+func (store *Store) snapshot() map[string][]map[string]interface{} {
+	snapshot := map[string][]map[string]interface{}{}
+	for name, entities := range store.entities {
+		snapshot[name] = append([]map[string]interface{}(nil), entities...)
+	}
+	return snapshot
+}
+
+func (store *Store) restore(snapshot map[string][]map[string]interface{}) {
+	store.entities = snapshot
+}
+
+// Add creates an entity as a create operation does, generating its id when
+// missing, and returns it.
+func (store *Store) Add(entityType string, data map[string]interface{}) (map[string]interface{}, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.create(entityType, data)
+}
+
+func (store *Store) Update(entityType string, key []string, data map[string]interface{}) (map[string]interface{}, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.update(entityType, key, data)
+}
+
+func (store *Store) Delete(entityType string, key []string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.delete(entityType, key)
+}
+
+// Get returns the entity with key of entityType or its subtypes, nil when
+// there is none.
+func (store *Store) Get(entityType string, key ...string) map[string]interface{} {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	typeName, index := store.find(entityType, key)
+	if index < 0 {
+		return nil
+	}
+	return copyEntity(typeName, store.entities[typeName][index])
+}
+
+// Entities returns the entities of entityType and its subtypes.
+func (store *Store) Entities(entityType string) []map[string]interface{} {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.all(entityType)
+}
+
+func (store *Store) all(name string) []map[string]interface{} {
+	var entities []map[string]interface{}
+	for _, typeName := range store.subtypes(name) {
+		for _, entity := range store.entities[typeName] {
+			entities = append(entities, copyEntity(typeName, entity))
+		}
+	}
+	return entities
+}
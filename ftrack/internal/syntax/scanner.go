@@ -0,0 +1,179 @@
+// Package syntax holds the tokenizer shared by the parsers of event
+// subscriptions and of query expressions.
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type SyntaxError struct {
+	Expression string
+	Position   int
+	Msg        string
+}
+
+func (error *SyntaxError) Error() string {
+	return fmt.Sprintf("SyntaxError: %s at position %d in %q", error.Msg, error.Position, error.Expression)
+}
+
+// Scanner reads the tokens of Input from Position. IsPathCharacter tells which
+// bytes make up attribute paths, keywords must not be followed by one.
+type Scanner struct {
+	Input           string
+	Position        int
+	IsPathCharacter func(c byte) bool
+}
+
+func (scanner *Scanner) Error(format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Expression: scanner.Input, Position: scanner.Position, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Rest returns the beginning of the unread input for error messages.
+func (scanner *Scanner) Rest() string {
+	rest := scanner.Input[scanner.Position:]
+	if len(rest) > 20 {
+		rest = rest[:20] + "..."
+	}
+	return rest
+}
+
+func (scanner *Scanner) AtEnd() bool {
+	return scanner.Position >= len(scanner.Input)
+}
+
+// End fails unless only whitespace is left.
+func (scanner *Scanner) End() error {
+	scanner.SkipSpace()
+	if !scanner.AtEnd() {
+		return scanner.Error("unexpected %q", scanner.Rest())
+	}
+	return nil
+}
+
+func (scanner *Scanner) SkipSpace() {
+	for !scanner.AtEnd() && unicode.IsSpace(rune(scanner.Input[scanner.Position])) {
+		scanner.Position++
+	}
+}
+
+// Keyword reads word, matched case insensitively, and reports whether it was
+// there. A word which is only the start of a path is not a keyword.
+func (scanner *Scanner) Keyword(word string) bool {
+	scanner.SkipSpace()
+	end := scanner.Position + len(word)
+	if end > len(scanner.Input) || !strings.EqualFold(scanner.Input[scanner.Position:end], word) {
+		return false
+	}
+	if end < len(scanner.Input) && scanner.IsPathCharacter(scanner.Input[end]) {
+		return false
+	}
+	scanner.Position = end
+	return true
+}
+
+// Symbol reads symbol and reports whether it was there.
+func (scanner *Scanner) Symbol(symbol string) bool {
+	scanner.SkipSpace()
+	if strings.HasPrefix(scanner.Input[scanner.Position:], symbol) {
+		scanner.Position += len(symbol)
+		return true
+	}
+	return false
+}
+
+// Expect reads symbol or fails.
+func (scanner *Scanner) Expect(symbol string) error {
+	if scanner.Symbol(symbol) {
+		return nil
+	}
+	if scanner.AtEnd() {
+		return scanner.Error("missing %s", symbol)
+	}
+	return scanner.Error("expected %s, got %q", symbol, scanner.Rest())
+}
+
+// Repeat calls parse once and again after each separator keyword, as for the
+// operands of and and or.
+func (scanner *Scanner) Repeat(separator string, parse func() error) error {
+	for {
+		if err := parse(); err != nil {
+			return err
+		}
+		if !scanner.Keyword(separator) {
+			return nil
+		}
+	}
+}
+
+// Path reads a dot separated attribute path.
+func (scanner *Scanner) Path() (string, error) {
+	scanner.SkipSpace()
+	start := scanner.Position
+	for !scanner.AtEnd() && scanner.IsPathCharacter(scanner.Input[scanner.Position]) {
+		scanner.Position++
+	}
+	path := scanner.Input[start:scanner.Position]
+	if path == "" {
+		if scanner.AtEnd() {
+			return "", scanner.Error("unexpected end of expression")
+		}
+		return "", scanner.Error("expected attribute path, got %q", scanner.Rest())
+	}
+	if strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		scanner.Position = start
+		return "", scanner.Error("invalid attribute path %q", path)
+	}
+	return path, nil
+}
+
+// Quoted reads a single or double quoted string with backslash escapes, it
+// reports false when the next token is not quoted.
+func (scanner *Scanner) Quoted() (string, bool, error) {
+	scanner.SkipSpace()
+	if scanner.AtEnd() {
+		return "", false, nil
+	}
+	quote := scanner.Input[scanner.Position]
+	if quote != '"' && quote != '\'' {
+		return "", false, nil
+	}
+	start := scanner.Position
+	var value strings.Builder
+	for scanner.Position++; !scanner.AtEnd(); scanner.Position++ {
+		c := scanner.Input[scanner.Position]
+		switch {
+		case c == '\\' && scanner.Position+1 < len(scanner.Input):
+			scanner.Position++
+			value.WriteByte(scanner.Input[scanner.Position])
+		case c == quote:
+			scanner.Position++
+			return value.String(), true, nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	scanner.Position = start
+	return "", true, scanner.Error("unterminated string")
+}
+
+// Word reads a run of characters up to whitespace or one of stops.
+func (scanner *Scanner) Word(stops string) (string, error) {
+	scanner.SkipSpace()
+	if scanner.AtEnd() {
+		return "", scanner.Error("expected value")
+	}
+	start := scanner.Position
+	for !scanner.AtEnd() {
+		c := scanner.Input[scanner.Position]
+		if unicode.IsSpace(rune(c)) || strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		scanner.Position++
+	}
+	if start == scanner.Position {
+		return "", scanner.Error("expected value, got %q", scanner.Rest())
+	}
+	return scanner.Input[start:scanner.Position], nil
+}
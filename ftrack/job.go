@@ -0,0 +1,172 @@
+package ftrack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const DefaultJobPollInterval = 2 * time.Second
+
+const (
+	JobStatusQueued  string = "queued"
+	JobStatusRunning string = "running"
+	JobStatusDone    string = "done"
+	JobStatusFailed  string = "failed"
+	JobStatusKilled  string = "killed"
+)
+
+type Job struct {
+	Id     string
+	Type   string
+	Status string
+	// Data is the job's data field decoded from JSON, nil when it is empty or
+	// not a JSON object. RawData holds the field as returned by the server.
+	Data    map[string]interface{}
+	RawData string
+	Entity  map[string]interface{}
+}
+
+func newJob(entity map[string]interface{}) *Job {
+	job := &Job{Entity: entity}
+	job.Id, _ = entity["id"].(string)
+	job.Type, _ = entity["type"].(string)
+	job.Status, _ = entity["status"].(string)
+	job.RawData, _ = entity["data"].(string)
+	if job.RawData != "" {
+		_ = json.Unmarshal([]byte(job.RawData), &job.Data)
+	}
+	return job
+}
+
+func (job *Job) Finished() bool {
+	return job.Status == JobStatusDone || job.Status == JobStatusFailed || job.Status == JobStatusKilled
+}
+
+// Progress returns the progress reported in the job data as a percentage.
+// Values between 0 and 1 are treated as fractions.
+func (job *Job) Progress() (float64, bool) {
+	progress, ok := job.Data["progress"].(float64)
+	if !ok {
+		return 0, false
+	}
+	if progress > 0 && progress <= 1 {
+		progress *= 100
+	}
+	return progress, true
+}
+
+func (job *Job) Description() string {
+	description, _ := job.Data["description"].(string)
+	return description
+}
+
+type JobError struct {
+	Job *Job
+}
+
+func (error *JobError) Error() string {
+	if description := error.Job.Description(); description != "" {
+		return fmt.Sprintf("JobError: job %s %s: %s", error.Job.Id, error.Job.Status, description)
+	}
+	return fmt.Sprintf("JobError: job %s %s", error.Job.Id, error.Job.Status)
+}
+
+type JobWatcherOptions struct {
+	PollInterval *time.Duration
+	// OnStatusChange is called when the job is first polled and whenever its
+	// status changes afterwards, previous is empty on the first call.
+	OnStatusChange *func(job *Job, previous string)
+	// OnData is called whenever the job data changes.
+	OnData *func(job *Job)
+}
+
+func (options *JobWatcherOptions) setDefaults() {
+	if options.PollInterval == nil || *options.PollInterval <= 0 {
+		interval := DefaultJobPollInterval
+		options.PollInterval = &interval
+	}
+}
+
+type JobWatcher struct {
+	session *Session
+	jobId   string
+	options JobWatcherOptions
+	job     *Job
+}
+
+func (session *Session) NewJobWatcher(jobId string, options JobWatcherOptions) *JobWatcher {
+	options.setDefaults()
+	return &JobWatcher{session: session, jobId: jobId, options: options}
+}
+
+// Job returns the job as of the last poll, nil before the first poll.
+func (watcher *JobWatcher) Job() *Job {
+	return watcher.job
+}
+
+// Poll fetches the job once and invokes the callbacks for any changes since
+// the previous poll.
+func (watcher *JobWatcher) Poll() (*Job, error) {
+	job, err := watcher.session.GetJob(watcher.jobId)
+	if err != nil {
+		return nil, err
+	}
+	previous := watcher.job
+	watcher.job = job
+	if previous == nil || previous.Status != job.Status {
+		previousStatus := ""
+		if previous != nil {
+			previousStatus = previous.Status
+		}
+		if watcher.options.OnStatusChange != nil {
+			(*watcher.options.OnStatusChange)(job, previousStatus)
+		}
+	}
+	if (previous == nil && job.RawData != "" || previous != nil && !reflect.DeepEqual(previous.Data, job.Data)) && watcher.options.OnData != nil {
+		(*watcher.options.OnData)(job)
+	}
+	return job, nil
+}
+
+// Wait polls the job until it finishes or ctx is done. Jobs that fail or are
+// killed are returned together with a *JobError.
+func (watcher *JobWatcher) Wait(ctx context.Context) (*Job, error) {
+	for {
+		job, err := watcher.Poll()
+		if err != nil {
+			return watcher.job, err
+		}
+		if job.Finished() {
+			if job.Status != JobStatusDone {
+				return job, &JobError{Job: job}
+			}
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(*watcher.options.PollInterval):
+		}
+	}
+}
+
+// WaitForJob polls the job every pollInterval until it finishes, see
+// JobWatcher.Wait.
+func (session *Session) WaitForJob(ctx context.Context, jobId string, pollInterval time.Duration) (*Job, error) {
+	return session.NewJobWatcher(jobId, JobWatcherOptions{PollInterval: &pollInterval}).Wait(ctx)
+}
+
+func (session *Session) GetJob(jobId string) (*Job, error) {
+	result, err := session.Query(fmt.Sprintf("select id, type, status, data from Job where id is %s", jobId))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.New(fmt.Sprintf("job %s not found", jobId))
+	}
+	return newJob(result.Data[0]), nil
+}
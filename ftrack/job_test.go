@@ -0,0 +1,85 @@
+package ftrack
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func jobResponse(states []map[string]interface{}) func([]map[string]interface{}) interface{} {
+	var mu sync.Mutex
+	poll := 0
+	return func(operations []map[string]interface{}) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		state := states[poll]
+		if poll < len(states)-1 {
+			poll++
+		}
+		job := map[string]interface{}{EntityTypeKey: "Job", "id": "job-1"}
+		for key, value := range state {
+			job[key] = value
+		}
+		return []interface{}{map[string]interface{}{"action": "query", "data": []interface{}{job}}}
+	}
+}
+
+func TestJobWatcher_Wait(t *testing.T) {
+	session, _ := newMockSession(t, jobResponse([]map[string]interface{}{
+		{"status": "queued"},
+		{"status": "running", "data": `{"progress": 0.25}`},
+		{"status": "running", "data": `{"progress": 0.75}`},
+		{"status": "done", "data": `{"progress": 1, "description": "exported"}`},
+	}))
+	var transitions []string
+	var progress []float64
+	onStatusChange := func(job *Job, previous string) {
+		transitions = append(transitions, previous+">"+job.Status)
+	}
+	onData := func(job *Job) {
+		value, _ := job.Progress()
+		progress = append(progress, value)
+	}
+	interval := time.Millisecond
+	watcher := session.NewJobWatcher("job-1", JobWatcherOptions{
+		PollInterval:   &interval,
+		OnStatusChange: &onStatusChange,
+		OnData:         &onData,
+	})
+	job, err := watcher.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, JobStatusDone, job.Status)
+	assert.Equal(t, "exported", job.Description())
+	assert.Equal(t, job, watcher.Job())
+	assert.Equal(t, []string{">queued", "queued>running", "running>done"}, transitions)
+	assert.Equal(t, []float64{25, 75, 100}, progress)
+}
+
+func TestSession_WaitForJobFailed(t *testing.T) {
+	session, _ := newMockSession(t, jobResponse([]map[string]interface{}{
+		{"status": "running"},
+		{"status": "failed", "data": `{"description": "no space left"}`},
+	}))
+	job, err := session.WaitForJob(context.Background(), "job-1", time.Millisecond)
+	var jobError *JobError
+	if !errors.As(err, &jobError) {
+		t.Fatalf("expected JobError, got %v", err)
+	}
+	assert.Equal(t, job, jobError.Job)
+	assert.Equal(t, JobStatusFailed, jobError.Job.Status)
+	assert.Contains(t, err.Error(), "no space left")
+}
+
+func TestSession_WaitForJobDeadline(t *testing.T) {
+	session, _ := newMockSession(t, jobResponse([]map[string]interface{}{{"status": "running"}}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	job, err := session.WaitForJob(ctx, "job-1", time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, JobStatusRunning, job.Status)
+}
@@ -0,0 +1,260 @@
+package ftrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"io"
+)
+
+const (
+	UnmanagedLocationId   string = "cb268ecc-8809-11e3-a7e2-20c9d081909b"
+	ServerLocationName    string = "ftrack.server"
+	UnmanagedLocationName string = "ftrack.unmanaged"
+)
+
+var ErrUnsupported = errors.New("operation not supported")
+
+// Accessor reads and writes component data addressed by resource identifiers.
+type Accessor interface {
+	Open(ctx context.Context, resourceIdentifier string) (io.ReadCloser, error)
+	Write(ctx context.Context, resourceIdentifier string, reader io.Reader, size int64) error
+	Exists(ctx context.Context, resourceIdentifier string) (bool, error)
+	Remove(ctx context.Context, resourceIdentifier string) error
+	GetFilesystemPath(resourceIdentifier string) (string, error)
+}
+
+// Structure computes the resource identifier of a component in a location.
+// Source is the filesystem path of the data being added when known.
+type Structure interface {
+	GetResourceIdentifier(session *Session, component map[string]interface{}, source string) (string, error)
+}
+
+type Location interface {
+	Id() string
+	Name() string
+	Accessor() Accessor
+	Structure() Structure
+	// Managed locations own their data, unmanaged locations only register
+	// data that already exists at its source.
+	Managed() bool
+}
+
+type BaseLocation struct {
+	id        string
+	name      string
+	accessor  Accessor
+	structure Structure
+	managed   bool
+}
+
+func NewLocation(id string, name string, accessor Accessor, structure Structure) *BaseLocation {
+	return &BaseLocation{id: id, name: name, accessor: accessor, structure: structure, managed: true}
+}
+
+func NewServerLocation(session *Session) *BaseLocation {
+	return NewLocation(ServerLocationId, ServerLocationName, &ServerAccessor{Session: session}, &EntityIdStructure{})
+}
+
+func NewDiskLocation(id string, name string, prefix string, structure Structure) *BaseLocation {
+	if structure == nil {
+		structure = &IdStructure{}
+	}
+	return NewLocation(id, name, &DiskAccessor{Prefix: prefix}, structure)
+}
+
+func NewUnmanagedLocation() *BaseLocation {
+	location := NewLocation(UnmanagedLocationId, UnmanagedLocationName, &DiskAccessor{}, &OriginStructure{})
+	location.managed = false
+	return location
+}
+
+func (location *BaseLocation) Id() string {
+	return location.id
+}
+
+func (location *BaseLocation) Name() string {
+	return location.name
+}
+
+func (location *BaseLocation) Accessor() Accessor {
+	return location.accessor
+}
+
+func (location *BaseLocation) Structure() Structure {
+	return location.structure
+}
+
+func (location *BaseLocation) Managed() bool {
+	return location.managed
+}
+
+func componentId(component map[string]interface{}) (string, error) {
+	id, ok := component["id"].(string)
+	if !ok || id == "" {
+		return "", errors.New(fmt.Sprintf("component has no id: %v", component))
+	}
+	return id, nil
+}
+
+// AddComponent writes the data read from reader to location and registers
+// the component there with a new ComponentLocation.
+func (session *Session) AddComponent(ctx context.Context, component map[string]interface{}, location Location, reader io.Reader, size int64) (*CreateResult, error) {
+	if !location.Managed() {
+		return nil, errors.New(fmt.Sprintf("location %s is unmanaged, use RegisterComponent", location.Name()))
+	}
+	return session.addComponent(ctx, component, location, "", func(resourceIdentifier string) error {
+		return location.Accessor().Write(ctx, resourceIdentifier, reader, size)
+	})
+}
+
+// AddComponentFromLocation copies the component data from source to target
+// and registers the component in target. Unmanaged targets only register the
+// filesystem path of the data in source.
+func (session *Session) AddComponentFromLocation(ctx context.Context, component map[string]interface{}, source Location, target Location) (*CreateResult, error) {
+	sourceIdentifier, err := session.GetResourceIdentifier(component, source)
+	if err != nil {
+		return nil, err
+	}
+	sourcePath, _ := source.Accessor().GetFilesystemPath(sourceIdentifier)
+	if !target.Managed() {
+		if sourcePath == "" {
+			return nil, errors.New(fmt.Sprintf("location %s has no filesystem path to register in %s", source.Name(), target.Name()))
+		}
+		return session.addComponent(ctx, component, target, sourcePath, nil)
+	}
+	populated, err := session.EnsurePopulated(component, []string{"size"})
+	if err != nil {
+		return nil, err
+	}
+	size, ok := toInt64(populated["size"])
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("component has invalid size: %v", populated["size"]))
+	}
+	return session.addComponent(ctx, component, target, sourcePath, func(resourceIdentifier string) error {
+		reader, err := source.Accessor().Open(ctx, sourceIdentifier)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = reader.Close() }()
+		return target.Accessor().Write(ctx, resourceIdentifier, reader, size)
+	})
+}
+
+// RegisterComponent registers existing data at resourceIdentifier without
+// transferring any bytes.
+func (session *Session) RegisterComponent(component map[string]interface{}, location Location, resourceIdentifier string) (*CreateResult, error) {
+	id, err := componentId(component)
+	if err != nil {
+		return nil, err
+	}
+	return session.Create("ComponentLocation", map[string]interface{}{
+		"id":                  uuid.Must(uuid.NewV4(), nil).String(),
+		"component_id":        id,
+		"location_id":         location.Id(),
+		"resource_identifier": resourceIdentifier,
+	})
+}
+
+func (session *Session) addComponent(ctx context.Context, component map[string]interface{}, location Location, source string, write func(resourceIdentifier string) error) (*CreateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resourceIdentifier, err := location.Structure().GetResourceIdentifier(session, component, source)
+	if err != nil {
+		return nil, err
+	}
+	if write != nil {
+		if err := write(resourceIdentifier); err != nil {
+			return nil, err
+		}
+	}
+	result, err := session.RegisterComponent(component, location, resourceIdentifier)
+	if err != nil && write != nil {
+		_ = location.Accessor().Remove(context.Background(), resourceIdentifier)
+	}
+	return result, err
+}
+
+// RemoveComponent removes the component data from a managed location and
+// deletes its ComponentLocation.
+func (session *Session) RemoveComponent(ctx context.Context, component map[string]interface{}, location Location) error {
+	id, err := componentId(component)
+	if err != nil {
+		return err
+	}
+	result, err := session.Query(fmt.Sprintf(
+		"select id, resource_identifier from ComponentLocation where component_id is %s and location_id is %s",
+		id, location.Id(),
+	))
+	if err != nil {
+		return err
+	}
+	if len(result.Data) == 0 {
+		return errors.New(fmt.Sprintf("component %s is not in location %s", id, location.Name()))
+	}
+	componentLocation := result.Data[0]
+	if location.Managed() {
+		resourceIdentifier, _ := componentLocation["resource_identifier"].(string)
+		if err := location.Accessor().Remove(ctx, resourceIdentifier); err != nil {
+			return err
+		}
+	}
+	_, err = session.Delete("ComponentLocation", []string{fmt.Sprint(componentLocation["id"])})
+	return err
+}
+
+// MoveComponent adds the component to target and then removes it from source.
+func (session *Session) MoveComponent(ctx context.Context, component map[string]interface{}, source Location, target Location) (*CreateResult, error) {
+	result, err := session.AddComponentFromLocation(ctx, component, source, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.RemoveComponent(ctx, component, source); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (session *Session) GetResourceIdentifier(component map[string]interface{}, location Location) (string, error) {
+	id, err := componentId(component)
+	if err != nil {
+		return "", err
+	}
+	result, err := session.Query(fmt.Sprintf(
+		"select resource_identifier from ComponentLocation where component_id is %s and location_id is %s",
+		id, location.Id(),
+	))
+	if err != nil {
+		return "", err
+	}
+	if len(result.Data) == 0 {
+		return "", errors.New(fmt.Sprintf("component %s is not in location %s", id, location.Name()))
+	}
+	resourceIdentifier, ok := result.Data[0]["resource_identifier"].(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("component %s has no resource identifier in location %s", id, location.Name()))
+	}
+	return resourceIdentifier, nil
+}
+
+func (session *Session) GetFilesystemPath(component map[string]interface{}, location Location) (string, error) {
+	resourceIdentifier, err := session.GetResourceIdentifier(component, location)
+	if err != nil {
+		return "", err
+	}
+	return location.Accessor().GetFilesystemPath(resourceIdentifier)
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch casted := value.(type) {
+	case float64:
+		return int64(casted), true
+	case int64:
+		return casted, true
+	case int:
+		return int64(casted), true
+	}
+	return 0, false
+}
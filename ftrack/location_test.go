@@ -0,0 +1,68 @@
+package ftrack
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSession_CreateComponentInLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ftrack-location")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if op["action"] == "query" {
+				results = append(results, map[string]interface{}{
+					"action": "query",
+					"data":   []interface{}{map[string]interface{}{"container_id": nil}},
+				})
+				continue
+			}
+			results = append(results, map[string]interface{}{"action": op["action"], "data": op["entity_data"]})
+		}
+		return results
+	})
+	location := NewDiskLocation("disk-location-id", "studio.disk", dir, nil)
+	content := []byte("published content")
+	fileName, fileType := "beauty", ".exr"
+	create, err := session.CreateComponentFromReader(context.Background(), bytes.NewReader(content), int64(len(content)), CreateComponentOptions{
+		FileName: &fileName,
+		FileType: &fileType,
+		Location: location,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, create, 2)
+	id := create[0].Data["id"].(string)
+	assert.Equal(t, "disk-location-id", create[1].Data["location_id"])
+	resourceIdentifier := create[1].Data["resource_identifier"].(string)
+	assert.Equal(t, idParts(id)+"/"+id+".exr", resourceIdentifier)
+	written, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(resourceIdentifier)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, written)
+	for _, batch := range server.Batches() {
+		assert.NotEqual(t, "get_upload_metadata", batch[0]["action"], "Should not upload to the server")
+	}
+}
+
+func TestUnmanagedLocation(t *testing.T) {
+	location := NewUnmanagedLocation()
+	assert.False(t, location.Managed())
+	assert.Equal(t, UnmanagedLocationId, location.Id())
+	resourceIdentifier, err := location.Structure().GetResourceIdentifier(nil, nil, "/mnt/renders/beauty.exr")
+	assert.Nil(t, err)
+	assert.Equal(t, "/mnt/renders/beauty.exr", resourceIdentifier)
+	_, err = location.Structure().GetResourceIdentifier(nil, nil, "")
+	assert.NotNil(t, err)
+}
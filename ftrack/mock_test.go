@@ -0,0 +1,140 @@
+package ftrack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var mockSchemaTypes = []string{
+	"Task", "User", "Status", "State", "FileComponent", "SequenceComponent",
+	"ContainerComponent", "ComponentLocation", "Location", "AssetVersion", "Job",
+	"Event", "Project", "Shot", "Sequence", "Asset",
+}
+
+type mockServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	batches  [][]map[string]interface{}
+	files    map[string][]byte
+	response func(operations []map[string]interface{}) interface{}
+}
+
+func (server *mockServer) File(id string) ([]byte, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	content, ok := server.files[id]
+	return content, ok
+}
+
+func (server *mockServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/expired/"):
+		w.WriteHeader(http.StatusForbidden)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/"):
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/upload/")
+		server.files[key] = content
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%s"`, key))
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.URL.Path == "/component/get":
+		if r.URL.Query().Get("apiKey") != "" {
+			// Downloads must use signed urls.
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		content, ok := server.files[r.URL.Query().Get("id")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// uploadMetadataResponse answers get_upload_metadata operations with urls
+// served by the mock server and every other operation with response.
+func (server *mockServer) uploadMetadataResponse(response func(op map[string]interface{}) interface{}) func(operations []map[string]interface{}) interface{} {
+	return func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if op["action"] == "get_upload_metadata" {
+				results = append(results, map[string]interface{}{
+					"url":     fmt.Sprintf("%s/upload/%s", server.URL, op["component_id"]),
+					"headers": map[string]string{"Content-Type": "application/octet-stream"},
+				})
+				continue
+			}
+			results = append(results, response(op))
+		}
+		return results
+	}
+}
+
+func (server *mockServer) Batches() [][]map[string]interface{} {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.batches
+}
+
+func (server *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != DefaultApiEndpoint {
+		server.serveFile(w, r)
+		return
+	}
+	var operations []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var body interface{}
+	if len(operations) > 0 && operations[0]["action"] == "query_server_information" {
+		var schemas []map[string]interface{}
+		for _, entityType := range mockSchemaTypes {
+			schemas = append(schemas, map[string]interface{}{
+				"id":          entityType,
+				"primary_key": []string{"id"},
+			})
+		}
+		body = []interface{}{
+			map[string]interface{}{"version": "4.0.0", "is_timezone_support_enabled": true},
+			schemas,
+		}
+	} else {
+		server.mu.Lock()
+		server.batches = append(server.batches, operations)
+		server.mu.Unlock()
+		body = server.response(operations)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newMockSession(t *testing.T, response func(operations []map[string]interface{}) interface{}) (*Session, *mockServer) {
+	server := &mockServer{response: response, files: map[string][]byte{}}
+	server.Server = httptest.NewServer(server)
+	t.Cleanup(server.Close)
+	session, err := NewSession(SessionConfig{
+		ApiKey:    "mock-api-key",
+		ApiUser:   "mock-user",
+		ServerUrl: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, server
+}
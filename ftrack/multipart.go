@@ -0,0 +1,404 @@
+package ftrack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMultipartPartSize    int64 = 64 * 1024 * 1024
+	DefaultMultipartConcurrency int   = 4
+	DefaultMultipartMaxRetries  int   = 3
+)
+
+type MultipartUploadOptions struct {
+	CreateComponentOptions
+	PartSize    *int64
+	Concurrency *int
+	MaxRetries  *int
+	// StatePath is where the upload state is persisted between attempts. When
+	// set, a failed upload keeps its entities so that calling again with the
+	// same StatePath resumes the remaining parts.
+	StatePath *string
+}
+
+func (options *MultipartUploadOptions) setDefaults() {
+	options.CreateComponentOptions.setDefaults()
+	if options.PartSize == nil {
+		partSize := DefaultMultipartPartSize
+		options.PartSize = &partSize
+	}
+	if options.Concurrency == nil {
+		concurrency := DefaultMultipartConcurrency
+		options.Concurrency = &concurrency
+	}
+	if options.MaxRetries == nil {
+		maxRetries := DefaultMultipartMaxRetries
+		options.MaxRetries = &maxRetries
+	}
+}
+
+type multipartUploadState struct {
+	ComponentId         uuid.UUID       `json:"component_id"`
+	ComponentLocationId uuid.UUID       `json:"component_location_id"`
+	FileSize            int64           `json:"file_size"`
+	PartSize            int64           `json:"part_size"`
+	UploadId            string          `json:"upload_id"`
+	Urls                []UploadPartUrl `json:"urls"`
+	Completed           map[int]string  `json:"completed"`
+
+	mu   sync.Mutex
+	path string
+}
+
+func loadMultipartUploadState(path string) (*multipartUploadState, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := multipartUploadState{path: path}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, err
+	}
+	if state.Completed == nil {
+		state.Completed = map[int]string{}
+	}
+	return &state, nil
+}
+
+func (state *multipartUploadState) complete(partNumber int, eTag string) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Completed[partNumber] = eTag
+	return state.save()
+}
+
+func (state *multipartUploadState) save() error {
+	if state.path == "" {
+		return nil
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := state.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, state.path)
+}
+
+func (state *multipartUploadState) parts() int {
+	parts := int((state.FileSize + state.PartSize - 1) / state.PartSize)
+	if parts == 0 {
+		parts = 1
+	}
+	return parts
+}
+
+// url returns the current url of a part, urls change when they are refreshed.
+func (state *multipartUploadState) url(partNumber int) UploadPartUrl {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, url := range state.Urls {
+		if url.PartNumber == partNumber {
+			return url
+		}
+	}
+	return UploadPartUrl{PartNumber: partNumber}
+}
+
+func (state *multipartUploadState) uploadedParts() []UploadedPart {
+	var parts []UploadedPart
+	for partNumber, eTag := range state.Completed {
+		parts = append(parts, UploadedPart{PartNumber: partNumber, ETag: eTag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts
+}
+
+func (session *Session) CreateComponentMultipart(ctx context.Context, fileName string, options MultipartUploadOptions) ([]CreateResult, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	if err := options.setFileDefaults(file); err != nil {
+		return nil, err
+	}
+	return session.CreateComponentFromReaderAt(ctx, file, *options.FileSize, options)
+}
+
+func (session *Session) CreateComponentFromReaderAt(ctx context.Context, reader io.ReaderAt, size int64, options MultipartUploadOptions) ([]CreateResult, error) {
+	if size < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid component size %d", size))
+	}
+	options.FileSize = &size
+	options.setDefaults()
+	if options.Location != nil && options.Location.Id() != ServerLocationId {
+		return nil, errors.New("multipart uploads are only supported by the server location")
+	}
+	if *options.PartSize <= 0 || *options.Concurrency <= 0 {
+		return nil, errors.New("PartSize and Concurrency must be positive")
+	}
+	var state *multipartUploadState
+	var err error
+	if options.StatePath != nil {
+		state, err = loadMultipartUploadState(*options.StatePath)
+		if err != nil {
+			return nil, err
+		}
+		if state != nil && state.FileSize != size {
+			return nil, errors.New(fmt.Sprintf("upload state %s is for a file of size %d", *options.StatePath, state.FileSize))
+		}
+	}
+	fileName := fmt.Sprintf("%s%s", *options.FileName, *options.FileType)
+	var result []CreateResult
+	if state != nil {
+		options.Id = &state.ComponentId
+		options.componentLocationId = state.ComponentLocationId
+		result, err = session.queryComponentEntities(options)
+		if err != nil {
+			return nil, err
+		}
+		// The part urls of the state may have expired since it was saved.
+		if err := session.refreshPartUrls(state, fileName); err != nil {
+			return nil, err
+		}
+	} else {
+		state = &multipartUploadState{
+			ComponentId:         *options.Id,
+			ComponentLocationId: options.componentLocationId,
+			FileSize:            size,
+			PartSize:            *options.PartSize,
+			Completed:           map[int]string{},
+		}
+		results, err := session.Call(NewGetMultipartUploadMetadataOperation(fileName, size, *options.Id, state.parts()))
+		if err != nil {
+			return nil, err
+		}
+		uploadMetadata := results[0].(GetUploadMetadataResult)
+		if uploadMetadata.UploadId == "" || len(uploadMetadata.Urls) != state.parts() {
+			return nil, errors.New("server does not support multipart uploads")
+		}
+		state.UploadId = uploadMetadata.UploadId
+		state.Urls = uploadMetadata.Urls
+		if options.StatePath != nil {
+			state.path = *options.StatePath
+		}
+		result, err = session.createComponentEntities(options.CreateComponentOptions)
+		if err != nil {
+			session.abortMultipartUpload(state)
+			return nil, err
+		}
+		if err := state.save(); err != nil {
+			session.abortMultipartUpload(state)
+			session.deleteComponent(options.CreateComponentOptions)
+			return nil, err
+		}
+	}
+	err = session.uploadParts(ctx, reader, state, fileName, options)
+	if err == nil {
+		_, err = session.Call(NewCompleteMultipartUploadOperation(state.ComponentId, state.UploadId, state.uploadedParts()))
+	}
+	if err != nil {
+		if state.path == "" {
+			session.abortMultipartUpload(state)
+			session.deleteComponent(options.CreateComponentOptions)
+		}
+		if ctx.Err() != nil {
+			(*options.OnAborted)()
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if state.path != "" {
+		_ = os.Remove(state.path)
+	}
+	return result, nil
+}
+
+// abortMultipartUpload releases the parts uploaded to the server, errors are
+// ignored as the upload has failed already.
+func (session *Session) abortMultipartUpload(state *multipartUploadState) {
+	_, _ = session.Call(NewAbortMultipartUploadOperation(state.ComponentId, state.UploadId))
+}
+
+// refreshPartUrls requests new signed urls for the parts of a started upload
+// and saves them with the state.
+func (session *Session) refreshPartUrls(state *multipartUploadState, fileName string) error {
+	operation := NewGetMultipartUploadMetadataOperation(fileName, state.FileSize, state.ComponentId, state.parts())
+	operation.UploadId = state.UploadId
+	results, err := session.Call(operation)
+	if err != nil {
+		return err
+	}
+	urls := results[0].(GetUploadMetadataResult).Urls
+	if len(urls) != state.parts() {
+		return errors.New(fmt.Sprintf("server returned %d part urls for upload %s, expected %d", len(urls), state.UploadId, state.parts()))
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Urls = urls
+	return state.save()
+}
+
+func (session *Session) queryComponentEntities(options MultipartUploadOptions) ([]CreateResult, error) {
+	results, err := session.Call(
+		NewQueryOperation(fmt.Sprintf("select name, size, file_type from FileComponent where id is %s", options.Id)),
+		NewQueryOperation(fmt.Sprintf("select component_id, location_id, resource_identifier from ComponentLocation where id is %s", options.componentLocationId)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var result []CreateResult
+	for _, r := range results {
+		query := r.(QueryResult)
+		if len(query.Data) != 1 {
+			return nil, errors.New(fmt.Sprintf("failed to resume upload of component %s, entities missing", options.Id))
+		}
+		result = append(result, CreateResult{Action: "create", Data: query.Data[0]})
+	}
+	return result, nil
+}
+
+func (session *Session) uploadParts(ctx context.Context, reader io.ReaderAt, state *multipartUploadState, fileName string, options MultipartUploadOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var uploaded int64
+	var pending []UploadPartUrl
+	for _, url := range state.Urls {
+		if _, ok := state.Completed[url.PartNumber]; ok {
+			uploaded += state.partLength(url.PartNumber)
+			continue
+		}
+		pending = append(pending, url)
+	}
+	tracker := options.newProgressTracker()
+	tracker.Add(uploaded)
+	report := tracker.Add
+	parts := make(chan UploadPartUrl)
+	errs := make(chan error, len(pending))
+	var wg sync.WaitGroup
+	for i := 0; i < *options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range parts {
+				eTag, err := session.uploadPartWithRetries(ctx, reader, state, part.PartNumber, fileName, *options.MaxRetries, report)
+				if err == nil {
+					err = state.complete(part.PartNumber, eTag)
+				}
+				if err != nil {
+					errs <- err
+					cancel()
+				}
+			}
+		}()
+	}
+dispatch:
+	for _, part := range pending {
+		select {
+		case parts <- part:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(parts)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (state *multipartUploadState) partLength(partNumber int) int64 {
+	offset := int64(partNumber-1) * state.PartSize
+	length := state.PartSize
+	if offset+length > state.FileSize {
+		length = state.FileSize - offset
+	}
+	return length
+}
+
+// errExpiredPartUrl is returned by uploadPart when the signed url of the part
+// is rejected, the urls are refreshed before the next attempt.
+var errExpiredPartUrl = errors.New("part url expired")
+
+func (session *Session) uploadPartWithRetries(ctx context.Context, reader io.ReaderAt, state *multipartUploadState, partNumber int, fileName string, maxRetries int, report func(int64)) (string, error) {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt*attempt) * 100 * time.Millisecond):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		if err == errExpiredPartUrl {
+			if refreshErr := session.refreshPartUrls(state, fileName); refreshErr != nil {
+				err = refreshErr
+				continue
+			}
+		}
+		var sent int64
+		var eTag string
+		eTag, err = session.uploadPart(ctx, reader, state, state.url(partNumber), func(c int64) {
+			sent += c
+			report(c)
+		})
+		if err == nil {
+			return eTag, nil
+		}
+		report(-sent)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	return "", errors.New(fmt.Sprintf("failed to upload part %d: %s", partNumber, err))
+}
+
+func (session *Session) uploadPart(ctx context.Context, reader io.ReaderAt, state *multipartUploadState, part UploadPartUrl, report func(int64)) (string, error) {
+	length := state.partLength(part.PartNumber)
+	offset := int64(part.PartNumber-1) * state.PartSize
+	request, err := http.NewRequestWithContext(
+		ctx,
+		"PUT",
+		part.SignedUrl,
+		&progressReader{Reader: io.NewSectionReader(reader, offset, length), Reporter: report},
+	)
+	if err != nil {
+		return "", err
+	}
+	request.ContentLength = length
+	if length == 0 {
+		request.Body = http.NoBody
+	}
+	response, err := session.transferClient().Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusForbidden {
+		return "", errExpiredPartUrl
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", newResponseError(response)
+	}
+	return strings.Trim(response.Header.Get("ETag"), `"`), nil
+}
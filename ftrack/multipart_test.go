@@ -0,0 +1,174 @@
+package ftrack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newMultipartSession(t *testing.T) (*Session, *mockServer) {
+	session, server := newMockSession(t, nil)
+	server.response = func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			switch op["action"] {
+			case "get_upload_metadata":
+				var urls []interface{}
+				for i := 1; i <= int(op["parts"].(float64)); i++ {
+					urls = append(urls, map[string]interface{}{
+						"part_number": i,
+						"signed_url":  fmt.Sprintf("%s/upload/%s/%d", server.URL, op["component_id"], i),
+					})
+				}
+				results = append(results, map[string]interface{}{"upload_id": "upload-1", "urls": urls})
+			case "complete_multipart_upload":
+				results = append(results, map[string]interface{}{})
+			default:
+				results = append(results, map[string]interface{}{"action": op["action"], "data": op["entity_data"]})
+			}
+		}
+		return results
+	}
+	return session, server
+}
+
+func TestSession_CreateComponentFromReaderAt(t *testing.T) {
+	session, server := newMultipartSession(t)
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	partSize := int64(10)
+	concurrency := 3
+	create, err := session.CreateComponentFromReaderAt(context.Background(), bytes.NewReader(content), int64(len(content)), MultipartUploadOptions{
+		PartSize:    &partSize,
+		Concurrency: &concurrency,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := create[0].Data["id"].(string)
+	var uploaded []byte
+	for i := 1; i <= 4; i++ {
+		part, ok := server.File(fmt.Sprintf("%s/%d", id, i))
+		assert.True(t, ok, "Should upload part %d", i)
+		uploaded = append(uploaded, part...)
+	}
+	assert.Equal(t, content, uploaded)
+	batches := server.Batches()
+	complete := batches[len(batches)-1][0]
+	assert.Equal(t, "complete_multipart_upload", complete["action"])
+	assert.Equal(t, "upload-1", complete["upload_id"])
+	assert.Len(t, complete["parts"], 4)
+	assert.Equal(t, fmt.Sprintf("etag-%s/1", id), complete["parts"].([]interface{})[0].(map[string]interface{})["e_tag"])
+}
+
+func TestSession_CreateComponentFromReaderAtResume(t *testing.T) {
+	session, server := newMultipartSession(t)
+	server.response = func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if op["action"] == "query" {
+				results = append(results, map[string]interface{}{"action": "query", "data": []interface{}{map[string]interface{}{}}})
+				continue
+			}
+			if op["action"] == "get_upload_metadata" {
+				results = append(results, map[string]interface{}{"upload_id": op["upload_id"], "urls": []interface{}{
+					map[string]interface{}{"part_number": 1, "signed_url": server.URL + "/upload/refreshed/1"},
+					map[string]interface{}{"part_number": 2, "signed_url": server.URL + "/upload/refreshed/2"},
+				}})
+				continue
+			}
+			results = append(results, map[string]interface{}{})
+		}
+		return results
+	}
+	dir, err := ioutil.TempDir("", "ftrack-multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "upload.json")
+	content := []byte("0123456789abcdefghij")
+	state := multipartUploadState{
+		FileSize: int64(len(content)),
+		PartSize: 10,
+		UploadId: "upload-1",
+		Urls: []UploadPartUrl{
+			{PartNumber: 1, SignedUrl: server.URL + "/expired/resumed/1"},
+			{PartNumber: 2, SignedUrl: server.URL + "/expired/resumed/2"},
+		},
+		Completed: map[int]string{1: "etag-resumed/1"},
+	}
+	encoded, _ := json.Marshal(&state)
+	if err := ioutil.WriteFile(statePath, encoded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.CreateComponentFromReaderAt(context.Background(), bytes.NewReader(content), int64(len(content)), MultipartUploadOptions{
+		StatePath: &statePath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := server.File("refreshed/1")
+	assert.False(t, ok, "Should not upload completed parts again")
+	part, _ := server.File("refreshed/2")
+	assert.Equal(t, content[10:], part, "Should upload with refreshed urls")
+	assert.Equal(t, "upload-1", server.Batches()[1][0]["upload_id"])
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err), "Should remove state after completion")
+}
+
+func TestSession_CreateComponentFromReaderAtExpiredUrl(t *testing.T) {
+	session, server := newMultipartSession(t)
+	multipart := server.response
+	refreshed := 0
+	server.response = func(operations []map[string]interface{}) interface{} {
+		op := operations[0]
+		if op["action"] != "get_upload_metadata" {
+			return multipart(operations)
+		}
+		prefix := "expired"
+		if op["upload_id"] != nil {
+			prefix = "upload"
+			refreshed++
+		}
+		return []interface{}{map[string]interface{}{"upload_id": "upload-1", "urls": []interface{}{
+			map[string]interface{}{"part_number": 1, "signed_url": fmt.Sprintf("%s/%s/%s/1", server.URL, prefix, op["component_id"])},
+		}}}
+	}
+	content := []byte("0123456789")
+	create, err := session.CreateComponentFromReaderAt(context.Background(), bytes.NewReader(content), int64(len(content)), MultipartUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, _ := server.File(create[0].Data["id"].(string) + "/1")
+	assert.Equal(t, content, part)
+	assert.Equal(t, 1, refreshed, "Should refresh the urls once")
+}
+
+func TestSession_CreateComponentFromReaderAtAbort(t *testing.T) {
+	session, server := newMultipartSession(t)
+	multipart := server.response
+	server.response = func(operations []map[string]interface{}) interface{} {
+		op := operations[0]
+		if op["action"] != "get_upload_metadata" {
+			return multipart(operations)
+		}
+		return []interface{}{map[string]interface{}{"upload_id": "upload-1", "urls": []interface{}{
+			map[string]interface{}{"part_number": 1, "signed_url": server.URL + "/missing/1"},
+		}}}
+	}
+	maxRetries := 0
+	_, err := session.CreateComponentFromReaderAt(context.Background(), bytes.NewReader([]byte("content")), 7, MultipartUploadOptions{MaxRetries: &maxRetries})
+	assert.NotNil(t, err)
+	var actions []interface{}
+	for _, batch := range server.Batches() {
+		actions = append(actions, batch[0]["action"])
+	}
+	assert.Contains(t, actions, "abort_multipart_upload")
+	assert.Equal(t, "delete", actions[len(actions)-1], "Should delete the component")
+}
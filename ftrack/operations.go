@@ -3,6 +3,7 @@ package ftrack
 import (
 	"fmt"
 	uuid "github.com/satori/go.uuid"
+	"time"
 )
 
 type QueryOperation struct {
@@ -185,11 +186,21 @@ type GetUploadMetadataOperation struct {
 	FileName    string    `json:"file_name"`
 	FileSize    int64     `json:"file_size"`
 	ComponentId uuid.UUID `json:"component_id"`
+	Parts       int       `json:"parts,omitempty"`
+	// UploadId requests new part urls for a started multipart upload.
+	UploadId string `json:"upload_id,omitempty"`
+}
+
+type UploadPartUrl struct {
+	PartNumber int    `json:"part_number"`
+	SignedUrl  string `json:"signed_url"`
 }
 
 type GetUploadMetadataResult struct {
-	Url     string            `json:"url"`
-	Headers map[string]string `json:"headers"`
+	Url      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	UploadId string            `json:"upload_id"`
+	Urls     []UploadPartUrl   `json:"urls"`
 }
 
 func NewGetUploadMetadataOperation(fileName string, fileSize int64, componentId uuid.UUID) GetUploadMetadataOperation {
@@ -207,3 +218,134 @@ func (op GetUploadMetadataOperation) ResultFactory(session *Session) *GetUploadM
 
 func (r *GetUploadMetadataResult) DecodeResult(session *Session, identityMap map[string]map[string]interface{}) {
 }
+
+func NewGetMultipartUploadMetadataOperation(fileName string, fileSize int64, componentId uuid.UUID, parts int) GetUploadMetadataOperation {
+	op := NewGetUploadMetadataOperation(fileName, fileSize, componentId)
+	op.Parts = parts
+	return op
+}
+
+type UploadedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"e_tag"`
+}
+
+type CompleteMultipartUploadOperation struct {
+	Action      string         `json:"action"`
+	ComponentId uuid.UUID      `json:"component_id"`
+	UploadId    string         `json:"upload_id"`
+	Parts       []UploadedPart `json:"parts"`
+}
+
+type CompleteMultipartUploadResult map[string]interface{}
+
+func NewCompleteMultipartUploadOperation(componentId uuid.UUID, uploadId string, parts []UploadedPart) CompleteMultipartUploadOperation {
+	return CompleteMultipartUploadOperation{
+		Action:      "complete_multipart_upload",
+		ComponentId: componentId,
+		UploadId:    uploadId,
+		Parts:       parts,
+	}
+}
+
+func (op CompleteMultipartUploadOperation) ResultFactory(session *Session) *CompleteMultipartUploadResult {
+	return &CompleteMultipartUploadResult{}
+}
+
+func (r *CompleteMultipartUploadResult) DecodeResult(session *Session, identityMap map[string]map[string]interface{}) {
+}
+
+type AbortMultipartUploadOperation struct {
+	Action      string    `json:"action"`
+	ComponentId uuid.UUID `json:"component_id"`
+	UploadId    string    `json:"upload_id"`
+}
+
+type AbortMultipartUploadResult map[string]interface{}
+
+func NewAbortMultipartUploadOperation(componentId uuid.UUID, uploadId string) AbortMultipartUploadOperation {
+	return AbortMultipartUploadOperation{
+		Action:      "abort_multipart_upload",
+		ComponentId: componentId,
+		UploadId:    uploadId,
+	}
+}
+
+func (op AbortMultipartUploadOperation) ResultFactory(session *Session) *AbortMultipartUploadResult {
+	return &AbortMultipartUploadResult{}
+}
+
+func (r *AbortMultipartUploadResult) DecodeResult(session *Session, identityMap map[string]map[string]interface{}) {
+}
+
+type GetSignedUrlOperation struct {
+	Action        string    `json:"action"`
+	ComponentId   uuid.UUID `json:"component_id"`
+	ExpiresIn     int       `json:"expires_in,omitempty"`
+	ThumbnailSize int       `json:"thumbnail_size,omitempty"`
+}
+
+type GetSignedUrlResult struct {
+	SignedUrl string `json:"signed_url"`
+}
+
+func NewGetSignedUrlOperation(componentId uuid.UUID, expiry time.Duration) GetSignedUrlOperation {
+	return GetSignedUrlOperation{
+		Action:      "get_signed_url",
+		ComponentId: componentId,
+		ExpiresIn:   int(expiry / time.Second),
+	}
+}
+
+func (op GetSignedUrlOperation) ResultFactory(session *Session) *GetSignedUrlResult {
+	return &GetSignedUrlResult{}
+}
+
+func (r *GetSignedUrlResult) DecodeResult(session *Session, identityMap map[string]map[string]interface{}) {
+}
+
+type KeepOriginal int
+
+const (
+	KeepOriginalAuto KeepOriginal = iota
+	KeepOriginalTrue
+	KeepOriginalFalse
+)
+
+func (keepOriginal KeepOriginal) MarshalJSON() ([]byte, error) {
+	switch keepOriginal {
+	case KeepOriginalTrue:
+		return []byte("true"), nil
+	case KeepOriginalFalse:
+		return []byte("false"), nil
+	default:
+		return []byte(`"auto"`), nil
+	}
+}
+
+type EncodeMediaOperation struct {
+	Action       string       `json:"action"`
+	ComponentId  uuid.UUID    `json:"component_id"`
+	VersionId    string       `json:"version_id,omitempty"`
+	KeepOriginal KeepOriginal `json:"keep_original"`
+}
+
+type EncodeMediaResult struct {
+	JobId string `json:"job_id"`
+}
+
+func NewEncodeMediaOperation(componentId uuid.UUID, versionId string, keepOriginal KeepOriginal) EncodeMediaOperation {
+	return EncodeMediaOperation{
+		Action:       "encode_media",
+		ComponentId:  componentId,
+		VersionId:    versionId,
+		KeepOriginal: keepOriginal,
+	}
+}
+
+func (op EncodeMediaOperation) ResultFactory(session *Session) *EncodeMediaResult {
+	return &EncodeMediaResult{}
+}
+
+func (r *EncodeMediaResult) DecodeResult(session *Session, identityMap map[string]map[string]interface{}) {
+}
@@ -0,0 +1,89 @@
+package ftrack
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultProgressInterval = 100 * time.Millisecond
+
+type Progress struct {
+	Bytes int64
+	Total int64
+	// Rate is the average transfer rate in bytes per second.
+	Rate float64
+	// ETA is the estimated time remaining, zero when it cannot be estimated.
+	ETA time.Duration
+}
+
+func (progress Progress) Percent() int {
+	if progress.Total == 0 {
+		return 100
+	}
+	if progress.Total < 0 {
+		return 0
+	}
+	return int(float64(progress.Bytes) / float64(progress.Total) * 100)
+}
+
+// progressTracker accumulates transferred bytes and reports them at most once
+// per interval, except for the final report which is always delivered.
+type progressTracker struct {
+	mu         sync.Mutex
+	progress   Progress
+	started    time.Time
+	reported   time.Time
+	finished   bool
+	interval   time.Duration
+	onPercent  func(int)
+	onProgress func(Progress)
+	now        func() time.Time
+}
+
+func newProgressTracker(total int64, interval *time.Duration, onPercent *func(int), onProgress *func(Progress)) *progressTracker {
+	tracker := &progressTracker{
+		progress: Progress{Total: total},
+		interval: DefaultProgressInterval,
+		now:      time.Now,
+	}
+	if interval != nil {
+		tracker.interval = *interval
+	}
+	if onPercent != nil {
+		tracker.onPercent = *onPercent
+	}
+	if onProgress != nil {
+		tracker.onProgress = *onProgress
+	}
+	tracker.started = tracker.now()
+	return tracker
+}
+
+func (tracker *progressTracker) Add(n int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.progress.Bytes += n
+	now := tracker.now()
+	done := tracker.progress.Total >= 0 && tracker.progress.Bytes >= tracker.progress.Total
+	if done && tracker.finished || !done && now.Sub(tracker.reported) < tracker.interval {
+		return
+	}
+	tracker.finished = done
+	tracker.reported = now
+	elapsed := now.Sub(tracker.started).Seconds()
+	tracker.progress.Rate = 0
+	tracker.progress.ETA = 0
+	if elapsed > 0 {
+		tracker.progress.Rate = float64(tracker.progress.Bytes) / elapsed
+	}
+	if tracker.progress.Rate > 0 && tracker.progress.Total > tracker.progress.Bytes {
+		remaining := float64(tracker.progress.Total-tracker.progress.Bytes) / tracker.progress.Rate
+		tracker.progress.ETA = time.Duration(remaining * float64(time.Second))
+	}
+	if tracker.onPercent != nil {
+		tracker.onPercent(tracker.progress.Percent())
+	}
+	if tracker.onProgress != nil {
+		tracker.onProgress(tracker.progress)
+	}
+}
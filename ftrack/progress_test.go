@@ -0,0 +1,36 @@
+package ftrack
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProgressTracker(t *testing.T) {
+	now := time.Unix(0, 0)
+	interval := time.Second
+	var percents []int
+	var reports []Progress
+	onPercent := func(p int) { percents = append(percents, p) }
+	onProgress := func(p Progress) { reports = append(reports, p) }
+	tracker := newProgressTracker(100, &interval, &onPercent, &onProgress)
+	tracker.now = func() time.Time { return now }
+	tracker.started = now
+
+	now = now.Add(time.Second)
+	tracker.Add(10)
+	now = now.Add(100 * time.Millisecond)
+	tracker.Add(10)
+	now = now.Add(time.Second)
+	tracker.Add(20)
+	tracker.Add(60)
+	tracker.Add(0)
+
+	assert.Equal(t, []int{10, 40, 100}, percents, "Should report cumulative throttled progress")
+	assert.Len(t, reports, 3)
+	assert.Equal(t, int64(40), reports[1].Bytes)
+	assert.Equal(t, int64(100), reports[1].Total)
+	assert.InDelta(t, 40/2.1, reports[1].Rate, 0.001)
+	assert.Equal(t, time.Duration(float64(60)/(40/2.1)*float64(time.Second)), reports[1].ETA)
+	assert.Equal(t, time.Duration(0), reports[2].ETA)
+}
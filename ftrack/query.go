@@ -0,0 +1,95 @@
+package ftrack
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/querylang"
+)
+
+const DefaultQueryPageSize int = 500
+
+// QueryPages runs expression one page of pageSize entities at a time, adding
+// offset and limit to it, and calls onPage with each page until the results
+// are exhausted or onPage returns an error.
+func (session *Session) QueryPages(expression string, pageSize int, onPage func(page []map[string]interface{}) error) error {
+	if pageSize <= 0 {
+		pageSize = DefaultQueryPageSize
+	}
+	if hasOffsetOrLimit(expression) {
+		return errors.New(fmt.Sprintf("expression already contains offset or limit: %s", expression))
+	}
+	offset := 0
+	for {
+		result, err := session.Query(fmt.Sprintf("%s offset %d limit %d", expression, offset, pageSize))
+		if err != nil {
+			return err
+		}
+		if len(result.Data) > 0 {
+			if err := onPage(result.Data); err != nil {
+				return err
+			}
+		}
+		next, ok := nextOffset(result.Metadata)
+		if !ok {
+			if len(result.Data) < pageSize {
+				return nil
+			}
+			next = offset + len(result.Data)
+		}
+		if next < 0 || next <= offset {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// hasOffsetOrLimit reports whether the query sets an offset or a limit.
+// Expressions the query language parser doesn't understand are left for the
+// server to validate.
+func hasOffsetOrLimit(expression string) bool {
+	query, err := querylang.ParseQuery(expression)
+	if err != nil {
+		return false
+	}
+	return query.Offset != 0 || query.Limit >= 0
+}
+
+// nextOffset reads the offset of the next page from the query metadata. A
+// missing next entry reports false, a null offset means there are no more
+// pages.
+func nextOffset(metadata map[string]interface{}) (int, bool) {
+	next, ok := metadata["next"]
+	if !ok {
+		return 0, false
+	}
+	casted, ok := next.(map[string]interface{})
+	if !ok {
+		return -1, true
+	}
+	offset, ok := toInt64(casted["offset"])
+	if !ok {
+		return -1, true
+	}
+	return int(offset), true
+}
+
+// QueryAll returns all entities matching expression, fetched with QueryPages.
+func (session *Session) QueryAll(expression string, pageSize int) ([]map[string]interface{}, error) {
+	var data []map[string]interface{}
+	err := session.QueryPages(expression, pageSize, func(page []map[string]interface{}) error {
+		data = append(data, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Filter returns the entities of the result matching a where clause such as
+// `status.name is "Done" and assignments any (resource.username is "john")`,
+// evaluated on the client. Only the attributes present in Data can be
+// matched, subqueries never match.
+func (result *QueryResult) Filter(expression string) ([]map[string]interface{}, error) {
+	return querylang.Filter(result.Data, expression)
+}
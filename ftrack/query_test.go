@@ -0,0 +1,96 @@
+package ftrack
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var pageExpression = regexp.MustCompile(`offset (\d+) limit (\d+)$`)
+
+// pagedResponse serves total Task entities page by page. With metadata the
+// next offset is reported like the server does.
+func pagedResponse(total int, metadata bool) func(operations []map[string]interface{}) interface{} {
+	return func(operations []map[string]interface{}) interface{} {
+		match := pageExpression.FindStringSubmatch(operations[0]["expression"].(string))
+		offset, _ := strconv.Atoi(match[1])
+		limit, _ := strconv.Atoi(match[2])
+		var data []interface{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			data = append(data, map[string]interface{}{EntityTypeKey: "Task", "id": fmt.Sprint(i)})
+		}
+		result := map[string]interface{}{"action": "query", "data": data}
+		if metadata {
+			var next interface{}
+			if offset+limit < total {
+				next = offset + limit
+			}
+			result["metadata"] = map[string]interface{}{"next": map[string]interface{}{"offset": next}}
+		}
+		return []interface{}{result}
+	}
+}
+
+func TestSession_QueryAll(t *testing.T) {
+	for _, metadata := range []bool{true, false} {
+		session, server := newMockSession(t, pagedResponse(25, metadata))
+		data, err := session.QueryAll("select id from Task", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, data, 25)
+		assert.Equal(t, "24", data[24]["id"])
+		assert.Len(t, server.Batches(), 3)
+		assert.Equal(t, "select id from Task offset 20 limit 10", server.Batches()[2][0]["expression"])
+	}
+}
+
+func TestSession_QueryPages(t *testing.T) {
+	session, server := newMockSession(t, pagedResponse(20, false))
+	var pages []int
+	err := session.QueryPages("select id from Task", 10, func(page []map[string]interface{}) error {
+		pages = append(pages, len(page))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 10}, pages)
+	assert.Len(t, server.Batches(), 3)
+
+	err = session.QueryPages("select id from Task limit 5", 10, func(page []map[string]interface{}) error { return nil })
+	assert.NotNil(t, err)
+
+	err = session.QueryPages(`select id from Task where name is "limit 5"`, 10, func(page []map[string]interface{}) error { return nil })
+	assert.Nil(t, err)
+}
+
+func TestQueryResult_Filter(t *testing.T) {
+	session, _ := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{"action": "query", "data": []interface{}{
+			map[string]interface{}{
+				EntityTypeKey: "Task", "id": "1", "name": "a",
+				"start_date": map[string]interface{}{"__type__": "datetime", "value": "2020-01-02T10:00:00"},
+				"status":     map[string]interface{}{EntityTypeKey: "Status", "id": "s1", "name": "Done"},
+			},
+			map[string]interface{}{
+				EntityTypeKey: "Task", "id": "2", "name": "b",
+				"start_date": map[string]interface{}{"__type__": "datetime", "value": "2019-01-02T10:00:00"},
+				"status":     map[string]interface{}{EntityTypeKey: "Status", "id": "s2", "name": "Blocked"},
+			},
+		}}}
+	})
+	result, err := session.Query("select name, start_date, status.name from Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := result.Filter(`status.name is "Done" or start_date before "2019-06-01"`)
+	assert.Nil(t, err)
+	assert.Len(t, filtered, 2)
+	filtered, err = result.Filter(`status.name is "Done" and start_date after "2020-01-01"`)
+	assert.Nil(t, err)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "1", filtered[0]["id"])
+	_, err = result.Filter("status.name is")
+	assert.NotNil(t, err)
+}
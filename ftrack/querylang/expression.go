@@ -0,0 +1,442 @@
+package querylang
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entity is an entity expressions are evaluated against. Get returns scalar
+// values as decoded from JSON, an Entity for relations and []Entity for
+// collections.
+type Entity interface {
+	Get(attribute string) (interface{}, bool)
+}
+
+// Source provides the entities subqueries select from.
+type Source interface {
+	// Entities returns the entities of entityType, including its subtypes.
+	Entities(entityType string) []Entity
+}
+
+// MapEntity is an entity as returned in QueryResult.Data, relations are nested
+// maps and collections are lists of maps.
+type MapEntity map[string]interface{}
+
+func (entity MapEntity) Get(attribute string) (interface{}, bool) {
+	value, ok := entity[attribute]
+	if !ok {
+		return nil, false
+	}
+	return wrap(value), true
+}
+
+func isDate(value map[string]interface{}) bool {
+	return value["__type__"] == "datetime"
+}
+
+func wrap(value interface{}) interface{} {
+	switch casted := value.(type) {
+	case map[string]interface{}:
+		if isDate(casted) {
+			return casted
+		}
+		return MapEntity(casted)
+	case []map[string]interface{}:
+		entities := make([]Entity, len(casted))
+		for i, item := range casted {
+			entities[i] = MapEntity(item)
+		}
+		return entities
+	case []interface{}:
+		entities := make([]Entity, 0, len(casted))
+		for _, item := range casted {
+			if item, ok := item.(map[string]interface{}); ok && !isDate(item) {
+				entities = append(entities, MapEntity(item))
+			} else {
+				return value
+			}
+		}
+		return entities
+	}
+	return value
+}
+
+// Expression is a compiled where clause such as
+// `status.state.short is "DONE" and versions any (version > 1)`.
+type Expression interface {
+	Match(entity Entity, source Source) bool
+	String() string
+}
+
+type And struct {
+	Expressions []Expression
+}
+
+func (and *And) Match(entity Entity, source Source) bool {
+	for _, expression := range and.Expressions {
+		if !expression.Match(entity, source) {
+			return false
+		}
+	}
+	return true
+}
+
+func (and *And) String() string {
+	return joinExpressions(and.Expressions, " and ")
+}
+
+type Or struct {
+	Expressions []Expression
+}
+
+func (or *Or) Match(entity Entity, source Source) bool {
+	for _, expression := range or.Expressions {
+		if expression.Match(entity, source) {
+			return true
+		}
+	}
+	return false
+}
+
+func (or *Or) String() string {
+	return joinExpressions(or.Expressions, " or ")
+}
+
+type Not struct {
+	Expression Expression
+}
+
+func (not *Not) Match(entity Entity, source Source) bool {
+	return !not.Expression.Match(entity, source)
+}
+
+func (not *Not) String() string {
+	return fmt.Sprintf("not %s", group(not.Expression))
+}
+
+// Relation matches when Expression matches one of the entities at Path.
+// Operator is "has" for relations and "any" for collections, both accept
+// either.
+type Relation struct {
+	Path       string
+	Operator   string
+	Expression Expression
+}
+
+func (relation *Relation) Match(entity Entity, source Source) bool {
+	for _, value := range Resolve(entity, relation.Path) {
+		if related, ok := value.(Entity); ok && relation.Expression.Match(related, source) {
+			return true
+		}
+	}
+	return false
+}
+
+func (relation *Relation) String() string {
+	return fmt.Sprintf("%s %s (%s)", relation.Path, relation.Operator, relation.Expression)
+}
+
+// Comparison compares the value at Path with Value. Value is a string, a
+// float64, a bool or nil for None.
+type Comparison struct {
+	Path     string
+	Operator string
+	Value    interface{}
+	pattern  *regexp.Regexp
+}
+
+func NewComparison(path string, operator string, value interface{}) *Comparison {
+	comparison := &Comparison{Path: path, Operator: operator, Value: value}
+	if operator == "like" || operator == "not_like" {
+		comparison.pattern = likePattern(formatValue(value))
+	}
+	return comparison
+}
+
+func likePattern(pattern string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expression.WriteString(".*")
+		case '_':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+func (comparison *Comparison) Match(entity Entity, source Source) bool {
+	for _, value := range Resolve(entity, comparison.Path) {
+		if comparison.matchValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (comparison *Comparison) matchValue(value interface{}) bool {
+	switch comparison.Operator {
+	case "is", "=":
+		return equal(value, comparison.Value)
+	case "is_not", "!=":
+		return !equal(value, comparison.Value)
+	case "like":
+		return value != nil && comparison.pattern.MatchString(formatValue(value))
+	case "not_like":
+		return value == nil || !comparison.pattern.MatchString(formatValue(value))
+	}
+	if value == nil || comparison.Value == nil {
+		return false
+	}
+	order := compare(value, comparison.Value)
+	switch comparison.Operator {
+	case "<", "before":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">", "after":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func (comparison *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", comparison.Path, comparison.Operator, formatLiteral(comparison.Value))
+}
+
+// In matches when the value at Path equals one of Values, or one of the
+// values selected by Subquery. Subqueries never match without a source.
+type In struct {
+	Path     string
+	Negated  bool
+	Values   []interface{}
+	Subquery *Query
+}
+
+func (in *In) Match(entity Entity, source Source) bool {
+	candidates := in.Values
+	if in.Subquery != nil {
+		if source == nil {
+			return false
+		}
+		candidates = in.Subquery.Values(source)
+	}
+	for _, value := range Resolve(entity, in.Path) {
+		found := false
+		for _, candidate := range candidates {
+			if equal(value, candidate) {
+				found = true
+				break
+			}
+		}
+		if found != in.Negated {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSubqueries returns expression with the subqueries replaced by the
+// values they select from source, so that they run once per filter rather
+// than once per entity. Without a source subqueries are kept and never match.
+func resolveSubqueries(expression Expression, source Source) Expression {
+	if source == nil {
+		return expression
+	}
+	resolveAll := func(expressions []Expression) []Expression {
+		resolved := make([]Expression, len(expressions))
+		for i, expression := range expressions {
+			resolved[i] = resolveSubqueries(expression, source)
+		}
+		return resolved
+	}
+	switch casted := expression.(type) {
+	case *And:
+		return &And{Expressions: resolveAll(casted.Expressions)}
+	case *Or:
+		return &Or{Expressions: resolveAll(casted.Expressions)}
+	case *Not:
+		return &Not{Expression: resolveSubqueries(casted.Expression, source)}
+	case *Relation:
+		return &Relation{Path: casted.Path, Operator: casted.Operator, Expression: resolveSubqueries(casted.Expression, source)}
+	case *In:
+		if casted.Subquery != nil {
+			return &In{Path: casted.Path, Negated: casted.Negated, Values: casted.Subquery.Values(source)}
+		}
+	}
+	return expression
+}
+
+func (in *In) String() string {
+	operator := "in"
+	if in.Negated {
+		operator = "not_in"
+	}
+	if in.Subquery != nil {
+		return fmt.Sprintf("%s %s (%s)", in.Path, operator, in.Subquery)
+	}
+	var values []string
+	for _, value := range in.Values {
+		values = append(values, formatLiteral(value))
+	}
+	return fmt.Sprintf("%s %s (%s)", in.Path, operator, strings.Join(values, ", "))
+}
+
+// joinExpressions prints the operands of an and or or where clause.
+func joinExpressions(expressions []Expression, separator string) string {
+	var parts []string
+	for _, expression := range expressions {
+		parts = append(parts, group(expression))
+	}
+	return strings.Join(parts, separator)
+}
+
+// group keeps nested and and or clauses in parentheses so that the printed
+// where clause, sent to the server or parsed back, has the same precedence.
+func group(expression Expression) string {
+	switch expression.(type) {
+	case *And, *Or:
+		return fmt.Sprintf("(%s)", expression)
+	}
+	return expression.String()
+}
+
+// Resolve returns the values at the dotted path, one for every entity of
+// the collections on the way. Missing attributes resolve to nil.
+func Resolve(entity Entity, path string) []interface{} {
+	values := []interface{}{entity}
+	for _, attribute := range strings.Split(path, ".") {
+		var next []interface{}
+		for _, value := range values {
+			var entities []Entity
+			switch casted := value.(type) {
+			case Entity:
+				entities = []Entity{casted}
+			case []Entity:
+				entities = casted
+			case nil:
+				// Attributes of a missing relation are None.
+				next = append(next, nil)
+				continue
+			default:
+				continue
+			}
+			for _, current := range entities {
+				resolved, _ := current.Get(attribute)
+				next = append(next, resolved)
+			}
+		}
+		values = next
+	}
+	var result []interface{}
+	for _, value := range values {
+		if entities, ok := value.([]Entity); ok {
+			for _, entity := range entities {
+				result = append(result, entity)
+			}
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+func formatValue(value interface{}) string {
+	switch casted := value.(type) {
+	case string:
+		return casted
+	case float64:
+		return strconv.FormatFloat(casted, 'f', -1, 64)
+	case map[string]interface{}:
+		if isDate(casted) {
+			return fmt.Sprint(casted["value"])
+		}
+	case nil:
+		return "None"
+	}
+	return fmt.Sprint(value)
+}
+
+func formatLiteral(value interface{}) string {
+	switch casted := value.(type) {
+	case string:
+		return strconv.Quote(casted)
+	case bool:
+		if casted {
+			return "True"
+		}
+		return "False"
+	}
+	return formatValue(value)
+}
+
+// toNumber returns numeric values as float64. Strings are not numbers, like
+// on the server "010" and "10" are different names.
+func toNumber(value interface{}) (float64, bool) {
+	switch casted := value.(type) {
+	case float64:
+		return casted, true
+	case int:
+		return float64(casted), true
+	case int64:
+		return float64(casted), true
+	}
+	return 0, false
+}
+
+// identity returns the id of entities, which compare by id, and other values
+// unchanged.
+func identity(value interface{}) interface{} {
+	if entity, ok := value.(Entity); ok {
+		id, _ := entity.Get("id")
+		return id
+	}
+	return value
+}
+
+func equal(value interface{}, literal interface{}) bool {
+	if value == nil || literal == nil {
+		return value == nil && literal == nil
+	}
+	if boolean, ok := value.(bool); ok {
+		switch casted := literal.(type) {
+		case bool:
+			return boolean == casted
+		case string:
+			return strings.EqualFold(casted, strconv.FormatBool(boolean))
+		}
+		return false
+	}
+	value = identity(value)
+	left, leftOk := toNumber(value)
+	right, rightOk := toNumber(literal)
+	if leftOk && rightOk {
+		return left == right
+	}
+	return formatValue(value) == formatValue(literal)
+}
+
+func compare(a interface{}, b interface{}) int {
+	a, b = identity(a), identity(b)
+	left, leftOk := toNumber(a)
+	right, rightOk := toNumber(b)
+	if leftOk && rightOk {
+		switch {
+		case left < right:
+			return -1
+		case left > right:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(formatValue(a), formatValue(b))
+}
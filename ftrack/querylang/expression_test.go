@@ -0,0 +1,116 @@
+package querylang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var task = MapEntity{
+	"__entity_type__": "Task",
+	"id":              "task-1",
+	"name":            "Compositing",
+	"code":            "010",
+	"bid":             4.5,
+	"is_milestone":    false,
+	"start_date":      map[string]interface{}{"__type__": "datetime", "value": "2020-01-02T10:00:00"},
+	"description":     nil,
+	"status": map[string]interface{}{
+		"id":    "status-1",
+		"name":  "In Progress",
+		"state": map[string]interface{}{"id": "state-1", "short": "IN_PROGRESS"},
+	},
+	"priority": nil,
+	"assignments": []interface{}{
+		map[string]interface{}{"resource": map[string]interface{}{"id": "user-1", "username": "john.doe"}},
+		map[string]interface{}{"resource": map[string]interface{}{"id": "user-2", "username": "jane.doe"}},
+	},
+	"children": []interface{}{},
+}
+
+func TestExpression_Match(t *testing.T) {
+	for expression, expected := range map[string]bool{
+		`name is "Compositing"`:                                  true,
+		`name = 'Compositing'`:                                   true,
+		`name is_not "Compositing"`:                              false,
+		`name != Lighting`:                                       true,
+		`name like "comp%"`:                                      true,
+		`name like "Comp_siting"`:                                true,
+		`name not_like "%ing"`:                                   false,
+		`bid > 4`:                                                true,
+		`bid >= 4.5 and bid <= 4.5`:                              true,
+		`bid < 4`:                                                false,
+		`is_milestone is False`:                                  true,
+		`is_milestone is True`:                                   false,
+		`description is None`:                                    true,
+		`description is_not None`:                                false,
+		`start_date after "2020-01-01"`:                          true,
+		`start_date before "2020-01-01"`:                         false,
+		`start_date >= "2020-01-02T10:00:00"`:                    true,
+		`status.name is "In Progress"`:                           true,
+		`status.state.short is IN_PROGRESS`:                      true,
+		`status.id is "status-1"`:                                true,
+		`status is "status-1"`:                                   true,
+		`priority is None`:                                       true,
+		`priority.name is None`:                                  true,
+		`status has (state.short is "IN_PROGRESS")`:              true,
+		`status has (state.short is "DONE")`:                     false,
+		`assignments any (resource.username is "jane.doe")`:      true,
+		`assignments any (resource.username is "joe")`:           false,
+		`assignments.resource.username is "john.doe"`:            true,
+		`children any (name like "%")`:                           false,
+		`name in ("Lighting", "Compositing")`:                    true,
+		`name not_in ("Lighting", "Compositing")`:                false,
+		`bid in (1, 4.5)`:                                        true,
+		`name is "Lighting" or (bid > 1 and not status is None)`: true,
+		`NOT name IS "Compositing" OR bid < 1`:                   false,
+		`missing is "x"`:                                         false,
+		`code is "010"`:                                          true,
+		`code is "10"`:                                           false,
+		`code is 10`:                                             false,
+		`bid is "4.5"`:                                           true,
+	} {
+		parsed, err := ParseExpression(expression)
+		if err != nil {
+			t.Fatalf("%s: %s", expression, err)
+		}
+		assert.Equal(t, expected, parsed.Match(task, nil), expression)
+		reparsed, err := ParseExpression(parsed.String())
+		assert.Nil(t, err, parsed.String())
+		assert.Equal(t, expected, reparsed.Match(task, nil), parsed.String())
+	}
+}
+
+func TestExpression_SyntaxErrors(t *testing.T) {
+	for expression, position := range map[string]int{
+		"":                                  0,
+		"name":                              4,
+		"name is":                           7,
+		"name is a and":                     13,
+		"name is a or (bid > 1":             21,
+		"name is a)":                        9,
+		"name ~ a":                          5,
+		`name is "unterminated`:             8,
+		"status has state.short=a":          11,
+		"name in a":                         8,
+		"name in (a, b":                     13,
+		"id in (select from Task)":          19,
+		"id in (select id, name from Task)": 7,
+		"status..name is a":                 0,
+	} {
+		_, err := ParseExpression(expression)
+		var syntaxError *SyntaxError
+		if !errors.As(err, &syntaxError) {
+			t.Fatalf("%q: expected SyntaxError, got %v", expression, err)
+		}
+		assert.Equal(t, position, syntaxError.Position, expression)
+		assert.Contains(t, err.Error(), "SyntaxError")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	assert.Equal(t, []interface{}{"john.doe", "jane.doe"}, Resolve(task, "assignments.resource.username"))
+	assert.Equal(t, []interface{}{nil}, Resolve(task, "priority.name"))
+	assert.Empty(t, Resolve(task, "children.name"))
+	assert.Equal(t, []interface{}{"IN_PROGRESS"}, Resolve(task, "status.state.short"))
+}
@@ -0,0 +1,325 @@
+package querylang
+
+import (
+	"github.com/conducte/ftrack-golang-api/ftrack/internal/syntax"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError reports the position at which a query is malformed.
+type SyntaxError = syntax.SyntaxError
+
+type parser struct {
+	syntax.Scanner
+}
+
+func newParser(expression string) *parser {
+	return &parser{syntax.Scanner{Input: expression, IsPathCharacter: isPathCharacter}}
+}
+
+// ParseQuery parses a query expression. The grammar is
+//
+//	query      := ["select" path ("," path)* "from"] type ["where" expression]
+//	              ["order by" path ["asc" | "desc"] ("," path ["asc" | "desc"])*]
+//	              ["offset" integer] ["limit" integer]
+//
+// offset and limit may come in either order.
+func ParseQuery(expression string) (*Query, error) {
+	parser := newParser(expression)
+	query, err := parser.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.End(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// ParseExpression parses a where clause. The grammar is
+//
+//	expression := term ("or" term)*
+//	term       := factor ("and" factor)*
+//	factor     := "not" factor | "(" expression ")"
+//	            | path ("has" | "any") "(" expression ")"
+//	            | path ("in" | "not_in") "(" (query | value ("," value)*) ")"
+//	            | path operator value
+//
+// where operator is one of is is_not = != < <= > >= like not_like before
+// after and value is a quoted string, a number, True, False, None or a run
+// of characters without whitespace, parentheses or commas.
+func ParseExpression(expression string) (Expression, error) {
+	parser := newParser(expression)
+	result, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.End(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func MustParseQuery(expression string) *Query {
+	query, err := ParseQuery(expression)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
+
+func (parser *parser) parseQuery() (*Query, error) {
+	query := &Query{Limit: -1}
+	if parser.Keyword("select") {
+		for {
+			path, err := parser.Path()
+			if err != nil {
+				return nil, err
+			}
+			query.Projections = append(query.Projections, path)
+			if !parser.Symbol(",") {
+				break
+			}
+		}
+		if !parser.Keyword("from") {
+			return nil, parser.Error("expected from, got %q", parser.Rest())
+		}
+	}
+	entityType, err := parser.Path()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(entityType, ".") {
+		return nil, parser.Error("invalid entity type %q", entityType)
+	}
+	query.EntityType = entityType
+	if parser.Keyword("where") {
+		if query.Where, err = parser.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if parser.Keyword("order") {
+		if !parser.Keyword("by") {
+			return nil, parser.Error("expected by after order")
+		}
+		for {
+			path, err := parser.Path()
+			if err != nil {
+				return nil, err
+			}
+			ordering := Ordering{Path: path}
+			if parser.Keyword("desc") {
+				ordering.Descending = true
+			} else {
+				parser.Keyword("asc")
+			}
+			query.OrderBy = append(query.OrderBy, ordering)
+			if !parser.Symbol(",") {
+				break
+			}
+		}
+	}
+	offset, limit := false, false
+	for {
+		var target *int
+		switch {
+		case !offset && parser.Keyword("offset"):
+			offset, target = true, &query.Offset
+		case !limit && parser.Keyword("limit"):
+			limit, target = true, &query.Limit
+		default:
+			return query, nil
+		}
+		value, err := parser.parseInteger()
+		if err != nil {
+			return nil, err
+		}
+		*target = value
+	}
+}
+
+func (parser *parser) parseInteger() (int, error) {
+	parser.SkipSpace()
+	start := parser.Position
+	for !parser.AtEnd() && parser.Input[parser.Position] >= '0' && parser.Input[parser.Position] <= '9' {
+		parser.Position++
+	}
+	if start == parser.Position {
+		return 0, parser.Error("expected integer, got %q", parser.Rest())
+	}
+	return strconv.Atoi(parser.Input[start:parser.Position])
+}
+
+func (parser *parser) parseOr() (Expression, error) {
+	var expressions []Expression
+	err := parser.Repeat("or", func() error {
+		expression, err := parser.parseAnd()
+		expressions = append(expressions, expression)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+	return &Or{Expressions: expressions}, nil
+}
+
+func (parser *parser) parseAnd() (Expression, error) {
+	var expressions []Expression
+	err := parser.Repeat("and", func() error {
+		expression, err := parser.parseFactor()
+		expressions = append(expressions, expression)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expressions) == 1 {
+		return expressions[0], nil
+	}
+	return &And{Expressions: expressions}, nil
+}
+
+var (
+	keywordOperators = []string{"is_not", "is", "not_like", "like", "before", "after"}
+	symbolOperators  = []string{"!=", "<=", ">=", "=", "<", ">"}
+)
+
+func (parser *parser) parseFactor() (Expression, error) {
+	if parser.Keyword("not") {
+		expression, err := parser.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expression: expression}, nil
+	}
+	if parser.Symbol("(") {
+		return parser.parseGroup()
+	}
+	path, err := parser.Path()
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"has", "any"} {
+		if !parser.Keyword(operator) {
+			continue
+		}
+		if !parser.Symbol("(") {
+			return nil, parser.Error("expected ( after %s", operator)
+		}
+		expression, err := parser.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &Relation{Path: path, Operator: operator, Expression: expression}, nil
+	}
+	for _, operator := range []string{"not_in", "in"} {
+		if parser.Keyword(operator) {
+			return parser.parseIn(path, operator == "not_in")
+		}
+	}
+	operator := ""
+	for _, candidate := range keywordOperators {
+		if parser.Keyword(candidate) {
+			operator = candidate
+			break
+		}
+	}
+	for _, candidate := range symbolOperators {
+		if operator == "" && parser.Symbol(candidate) {
+			operator = candidate
+		}
+	}
+	if operator == "" {
+		if parser.AtEnd() {
+			return nil, parser.Error("expected operator after %s", path)
+		}
+		return nil, parser.Error("expected operator after %s, got %q", path, parser.Rest())
+	}
+	value, err := parser.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return NewComparison(path, operator, value), nil
+}
+
+func (parser *parser) parseIn(path string, negated bool) (Expression, error) {
+	if !parser.Symbol("(") {
+		return nil, parser.Error("expected ( after in")
+	}
+	in := &In{Path: path, Negated: negated}
+	start := parser.Position
+	if parser.Keyword("select") {
+		parser.Position = start
+		subquery, err := parser.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if len(subquery.Projections) != 1 {
+			parser.Position = start
+			return nil, parser.Error("subquery must select a single attribute")
+		}
+		in.Subquery = subquery
+	} else {
+		for {
+			value, err := parser.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			in.Values = append(in.Values, value)
+			if !parser.Symbol(",") {
+				break
+			}
+		}
+	}
+	if err := parser.Expect(")"); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// parseGroup reads a where clause nested in parentheses, by itself or as the
+// condition of has and any.
+func (parser *parser) parseGroup() (Expression, error) {
+	expression, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.Expect(")"); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+func isPathCharacter(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseValue returns quoted strings as string, numbers as float64, True and
+// False as bool, None as nil and other words as string.
+func (parser *parser) parseValue() (interface{}, error) {
+	if value, quoted, err := parser.Quoted(); quoted {
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	word, err := parser.Word("(),")
+	if err != nil {
+		return nil, err
+	}
+	switch word {
+	case "None", "null":
+		return nil, nil
+	case "True", "true":
+		return true, nil
+	case "False", "false":
+		return false, nil
+	}
+	if number, err := strconv.ParseFloat(word, 64); err == nil {
+		return number, nil
+	}
+	return word, nil
+}
@@ -0,0 +1,146 @@
+package querylang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Ordering struct {
+	Path       string
+	Descending bool
+}
+
+// Query is a parsed query expression such as
+// `select name, status.name from Task where project.name is "demo" order by name limit 10`.
+type Query struct {
+	Projections []string
+	EntityType  string
+	// Where is nil for queries without a where clause.
+	Where   Expression
+	OrderBy []Ordering
+	Offset  int
+	// Limit is -1 for queries without a limit.
+	Limit int
+}
+
+func (query *Query) String() string {
+	var parts []string
+	if len(query.Projections) > 0 {
+		parts = append(parts, "select", strings.Join(query.Projections, ", "), "from")
+	}
+	parts = append(parts, query.EntityType)
+	if query.Where != nil {
+		parts = append(parts, "where", query.Where.String())
+	}
+	if len(query.OrderBy) > 0 {
+		var orderings []string
+		for _, ordering := range query.OrderBy {
+			if ordering.Descending {
+				orderings = append(orderings, ordering.Path+" desc")
+			} else {
+				orderings = append(orderings, ordering.Path)
+			}
+		}
+		parts = append(parts, "order by", strings.Join(orderings, ", "))
+	}
+	if query.Offset > 0 {
+		parts = append(parts, fmt.Sprintf("offset %d", query.Offset))
+	}
+	if query.Limit >= 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", query.Limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Filter returns the entities matching the where clause in the query order.
+// Source is used for subqueries and may be nil.
+func (query *Query) Filter(entities []Entity, source Source) []Entity {
+	var matching []Entity
+	where := query.Where
+	if where != nil {
+		where = resolveSubqueries(where, source)
+	}
+	for _, entity := range entities {
+		if where == nil || where.Match(entity, source) {
+			matching = append(matching, entity)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		for _, ordering := range query.OrderBy {
+			order := compareOrdering(first(matching[i], ordering.Path), first(matching[j], ordering.Path))
+			if order != 0 {
+				return order < 0 != ordering.Descending
+			}
+		}
+		return false
+	})
+	return matching
+}
+
+// Page applies the offset and limit of the query to entities.
+func (query *Query) Page(entities []Entity) []Entity {
+	if query.Offset >= len(entities) {
+		return nil
+	}
+	entities = entities[query.Offset:]
+	if query.Limit >= 0 && query.Limit < len(entities) {
+		entities = entities[:query.Limit]
+	}
+	return entities
+}
+
+// Run returns the page of entities of source selected by the query.
+func (query *Query) Run(source Source) []Entity {
+	return query.Page(query.Filter(source.Entities(query.EntityType), source))
+}
+
+// Values returns the values of the first projection of the entities
+// selected by the query, as used by subqueries.
+func (query *Query) Values(source Source) []interface{} {
+	if len(query.Projections) == 0 {
+		return nil
+	}
+	var values []interface{}
+	for _, entity := range query.Run(source) {
+		values = append(values, Resolve(entity, query.Projections[0])...)
+	}
+	return values
+}
+
+func first(entity Entity, path string) interface{} {
+	values := Resolve(entity, path)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// compareOrdering orders None before any value.
+func compareOrdering(a interface{}, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compare(a, b)
+}
+
+// Filter returns the entities of data, such as QueryResult.Data, matching the
+// where clause expression.
+func Filter(data []map[string]interface{}, expression string) ([]map[string]interface{}, error) {
+	where, err := ParseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	var matching []map[string]interface{}
+	for _, entity := range data {
+		if where.Match(MapEntity(entity), nil) {
+			matching = append(matching, entity)
+		}
+	}
+	return matching, nil
+}
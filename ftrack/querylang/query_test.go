@@ -0,0 +1,129 @@
+package querylang
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type mapSource map[string][]map[string]interface{}
+
+func (source mapSource) Entities(entityType string) []Entity {
+	var entities []Entity
+	for _, entity := range source[entityType] {
+		entities = append(entities, MapEntity(entity))
+	}
+	return entities
+}
+
+var source = mapSource{
+	"Task": {
+		{"id": "1", "name": "b", "bid": 2.0, "status_id": "s1"},
+		{"id": "2", "name": "a", "bid": 1.0, "status_id": "s2"},
+		{"id": "3", "name": "c", "bid": 2.0, "status_id": nil},
+	},
+	"Status": {
+		{"id": "s1", "name": "Done"},
+		{"id": "s2", "name": "Blocked"},
+	},
+}
+
+func ids(entities []Entity) []interface{} {
+	var result []interface{}
+	for _, entity := range entities {
+		id, _ := entity.Get("id")
+		result = append(result, id)
+	}
+	return result
+}
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`select name, status.name from Task where bid > 1 order by bid desc, name limit 10 offset 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"name", "status.name"}, query.Projections)
+	assert.Equal(t, "Task", query.EntityType)
+	assert.Equal(t, "bid > 1", query.Where.String())
+	assert.Equal(t, []Ordering{{Path: "bid", Descending: true}, {Path: "name"}}, query.OrderBy)
+	assert.Equal(t, 5, query.Offset)
+	assert.Equal(t, 10, query.Limit)
+	assert.Equal(t, `select name, status.name from Task where bid > 1 order by bid desc, name offset 5 limit 10`, query.String())
+
+	query, err = ParseQuery("Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, query.Where)
+	assert.Equal(t, -1, query.Limit)
+	assert.Equal(t, "Task", query.String())
+
+	for _, expression := range []string{
+		"select name Task",
+		"select name from",
+		"Task where",
+		"Task order name",
+		"Task limit x",
+		"Task limit 1 limit 2",
+		"Task.name",
+	} {
+		_, err := ParseQuery(expression)
+		var syntaxError *SyntaxError
+		assert.True(t, errors.As(err, &syntaxError), expression)
+	}
+}
+
+func TestQuery_Run(t *testing.T) {
+	for expression, expected := range map[string][]interface{}{
+		"Task":                                   {"1", "2", "3"},
+		"Task order by name":                     {"2", "1", "3"},
+		"Task order by bid desc, name":           {"1", "3", "2"},
+		"Task order by status_id":                {"3", "1", "2"},
+		"Task order by name offset 1":            {"1", "3"},
+		"Task order by name limit 1 offset 2":    {"3"},
+		"Task offset 5":                          nil,
+		"Task where bid is 2 order by name desc": {"3", "1"},
+		`select id from Task where status_id in (select id from Status where name is "Done")`:     {"1"},
+		`select id from Task where status_id not_in (select id from Status where name is "Done")`: {"2", "3"},
+	} {
+		query := MustParseQuery(expression)
+		assert.Equal(t, expected, ids(query.Run(source)), expression)
+	}
+	assert.Equal(t, []interface{}{"s1", "s2"}, MustParseQuery("select id from Status").Values(source))
+	// Subqueries need a source.
+	query := MustParseQuery("Task where status_id in (select id from Status)")
+	assert.Empty(t, query.Filter(source.Entities("Task"), nil))
+}
+
+// countingSource counts the entities of each type requested from it.
+type countingSource struct {
+	mapSource
+	requests map[string]int
+}
+
+func (source *countingSource) Entities(entityType string) []Entity {
+	source.requests[entityType]++
+	return source.mapSource.Entities(entityType)
+}
+
+func TestQuery_FilterRunsSubqueriesOnce(t *testing.T) {
+	counting := &countingSource{mapSource: source, requests: map[string]int{}}
+	query := MustParseQuery(`Task where status_id in (select id from Status where name is "Done") or name is "c"`)
+	assert.Equal(t, []interface{}{"1", "3"}, ids(query.Run(counting)))
+	assert.Equal(t, 1, counting.requests["Status"])
+}
+
+func TestFilter(t *testing.T) {
+	data := []map[string]interface{}{
+		{"id": "1", "status": map[string]interface{}{"name": "Done"}},
+		{"id": "2", "status": map[string]interface{}{"name": "Blocked"}},
+	}
+	filtered, err := Filter(data, `status.name is "Done"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data[:1], filtered)
+
+	_, err = Filter(data, "status.name is")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,232 @@
+package ftrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const FrameRangeMetadataKey string = "frame_range"
+
+var framePattern = regexp.MustCompile(`%(0?)(\d*)d`)
+
+type CreateSequenceComponentOptions struct {
+	Id       *uuid.UUID
+	Name     *string
+	FileType *string
+	// Padding defaults to the width of the frame token in the pattern.
+	Padding     *int
+	Concurrency *int
+	// OnProgress and OnTransferProgress report progress across all frames.
+	OnProgress         *func(int)
+	OnTransferProgress *func(Progress)
+	ProgressInterval   *time.Duration
+	OnAborted          *func()
+}
+
+func (options *CreateSequenceComponentOptions) setDefaults(pattern string) error {
+	match := framePattern.FindStringSubmatchIndex(pattern)
+	if match == nil || len(framePattern.FindAllStringIndex(pattern, -1)) != 1 {
+		return errors.New(fmt.Sprintf("pattern %s must contain exactly one frame token like %%04d", pattern))
+	}
+	if options.Id == nil {
+		id := uuid.Must(uuid.NewV4(), nil)
+		options.Id = &id
+	}
+	if options.FileType == nil {
+		ext := filepath.Ext(NormalizeString(pattern))
+		if framePattern.MatchString(ext) {
+			ext = ""
+		}
+		options.FileType = &ext
+	}
+	if options.Name == nil {
+		base := filepath.Base(NormalizeString(pattern[:match[0]]))
+		name := strings.TrimRight(base, "._- ")
+		if name == "" {
+			name = options.Id.String()
+		}
+		options.Name = &name
+	}
+	normalizedName := NormalizeString(*options.Name)
+	options.Name = &normalizedName
+	if options.Padding == nil {
+		padding := 0
+		if pattern[match[2]:match[3]] == "0" {
+			padding, _ = strconv.Atoi(pattern[match[4]:match[5]])
+		}
+		options.Padding = &padding
+	}
+	if options.Concurrency == nil {
+		concurrency := DefaultMultipartConcurrency
+		options.Concurrency = &concurrency
+	}
+	if options.OnAborted == nil {
+		onAborted := func() {}
+		options.OnAborted = &onAborted
+	}
+	return nil
+}
+
+// FormatFrameRange formats frames as a compact range, e.g. "1-3,5,7-9".
+func FormatFrameRange(frames []int) string {
+	sorted := append([]int(nil), frames...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// CreateSequenceComponent creates a SequenceComponent in the server location
+// with one member FileComponent per frame. The pattern contains a printf
+// style frame token, e.g. "/renders/shot.%04d.exr". The result holds the
+// container and its ComponentLocation followed by each member and its
+// ComponentLocation in frame order. When any upload fails every created
+// entity is removed.
+func (session *Session) CreateSequenceComponent(ctx context.Context, pattern string, frames []int, options CreateSequenceComponentOptions) ([]CreateResult, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("no frames given")
+	}
+	if err := options.setDefaults(pattern); err != nil {
+		return nil, err
+	}
+	frames = append([]int(nil), frames...)
+	sort.Ints(frames)
+	var total int64
+	sizes := make([]int64, len(frames))
+	for i, frame := range frames {
+		stat, err := os.Stat(fmt.Sprintf(pattern, frame))
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = stat.Size()
+		total += stat.Size()
+	}
+	containerLocationId := uuid.Must(uuid.NewV4(), nil)
+	results, err := session.Call(
+		NewCreateOperation("SequenceComponent", map[string]interface{}{
+			"id":        options.Id.String(),
+			"name":      *options.Name,
+			"file_type": *options.FileType,
+			"padding":   *options.Padding,
+			"size":      total,
+		}),
+		NewCreateOperation("ComponentLocation", map[string]interface{}{
+			"id":                  containerLocationId.String(),
+			"component_id":        options.Id.String(),
+			"location_id":         ServerLocationId,
+			"resource_identifier": options.Id.String(),
+		}),
+		NewCreateOperation("Metadata", map[string]interface{}{
+			"parent_id":   options.Id.String(),
+			"parent_type": "Component",
+			"key":         FrameRangeMetadataKey,
+			"value":       FormatFrameRange(frames),
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rollback := []interface{}{
+		NewDeleteOperation("Metadata", []string{options.Id.String(), FrameRangeMetadataKey}),
+		NewDeleteOperation("ComponentLocation", []string{containerLocationId.String()}),
+		NewDeleteOperation("SequenceComponent", []string{options.Id.String()}),
+	}
+	result := []CreateResult{results[0].(CreateResult), results[1].(CreateResult)}
+
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := newProgressTracker(total, options.ProgressInterval, options.OnProgress, options.OnTransferProgress)
+	members := make([][]CreateResult, len(frames))
+	indices := make(chan int)
+	errs := make(chan error, len(frames))
+	var wg sync.WaitGroup
+	for i := 0; i < *options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				member, err := session.createSequenceMember(uploadCtx, pattern, frames[index], sizes[index], tracker, options)
+				if err != nil {
+					errs <- err
+					cancel()
+					continue
+				}
+				members[index] = member
+			}
+		}()
+	}
+dispatch:
+	for i := range frames {
+		select {
+		case indices <- i:
+		case <-uploadCtx.Done():
+			break dispatch
+		}
+	}
+	close(indices)
+	wg.Wait()
+	close(errs)
+	err = <-errs
+	if err == nil {
+		err = uploadCtx.Err()
+	}
+	if err != nil {
+		var memberRollback []interface{}
+		for _, member := range members {
+			if member == nil {
+				continue
+			}
+			memberRollback = append(memberRollback,
+				NewDeleteOperation("ComponentLocation", []string{fmt.Sprint(member[1].Data["id"])}),
+				NewDeleteOperation("FileComponent", []string{fmt.Sprint(member[0].Data["id"])}),
+			)
+		}
+		_, _ = session.Call(append(memberRollback, rollback...)...)
+		if ctx.Err() != nil {
+			(*options.OnAborted)()
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	for _, member := range members {
+		result = append(result, member...)
+	}
+	return result, nil
+}
+
+func (session *Session) createSequenceMember(ctx context.Context, pattern string, frame int, size int64, tracker *progressTracker, options CreateSequenceComponentOptions) ([]CreateResult, error) {
+	file, err := os.Open(fmt.Sprintf(pattern, frame))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	id := uuid.Must(uuid.NewV4(), nil)
+	name := strconv.Itoa(frame)
+	return session.CreateComponentFromReader(ctx, &progressReader{Reader: file, Reporter: tracker.Add}, size, CreateComponentOptions{
+		Id:          &id,
+		FileName:    &name,
+		FileType:    options.FileType,
+		containerId: options.Id,
+	})
+}
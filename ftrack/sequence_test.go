@@ -0,0 +1,92 @@
+package ftrack
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func writeSequence(t *testing.T, frames []int) string {
+	dir, err := ioutil.TempDir("", "ftrack-sequence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	pattern := filepath.Join(dir, "shot_010.%04d.exr")
+	for _, frame := range frames {
+		if err := ioutil.WriteFile(fmt.Sprintf(pattern, frame), []byte(fmt.Sprintf("frame %d", frame)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pattern
+}
+
+func TestFormatFrameRange(t *testing.T) {
+	assert.Equal(t, "1-3,5,7-9", FormatFrameRange([]int{9, 1, 2, 3, 5, 7, 8}))
+	assert.Equal(t, "10", FormatFrameRange([]int{10}))
+}
+
+func TestSession_CreateSequenceComponent(t *testing.T) {
+	frames := []int{1001, 1002, 1003}
+	pattern := writeSequence(t, frames)
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	create, err := session.CreateSequenceComponent(context.Background(), pattern, frames, CreateSequenceComponentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, create, 8)
+	container := create[0].Data
+	assert.Equal(t, "SequenceComponent", container[EntityTypeKey])
+	assert.Equal(t, "shot_010", container["name"])
+	assert.Equal(t, ".exr", container["file_type"])
+	assert.Equal(t, float64(4), container["padding"])
+	for i, frame := range frames {
+		member := create[2+i*2].Data
+		assert.Equal(t, fmt.Sprint(frame), member["name"])
+		assert.Equal(t, container["id"], member["container_id"])
+		content, _ := server.File(member["id"].(string))
+		assert.Equal(t, fmt.Sprintf("frame %d", frame), string(content))
+	}
+	metadata := server.Batches()[0][2]
+	assert.Equal(t, "Metadata", metadata["entity_type"])
+	assert.Equal(t, "1001-1003", metadata["entity_data"].(map[string]interface{})["value"])
+}
+
+func TestSession_CreateSequenceComponentRollback(t *testing.T) {
+	frames := []int{1, 2, 3, 4}
+	pattern := writeSequence(t, frames)
+	session, server := newMockSession(t, nil)
+	var uploads int32
+	echo := server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	server.response = func(operations []map[string]interface{}) interface{} {
+		if operations[0]["action"] == "get_upload_metadata" && atomic.AddInt32(&uploads, 1) == 3 {
+			return []interface{}{map[string]interface{}{"url": server.URL + "/invalid"}}
+		}
+		return echo(operations)
+	}
+	concurrency := 1
+	_, err := session.CreateSequenceComponent(context.Background(), pattern, frames, CreateSequenceComponentOptions{Concurrency: &concurrency})
+	assert.NotNil(t, err)
+	batches := server.Batches()
+	rollback := batches[len(batches)-1]
+	var deleted []string
+	for _, op := range rollback {
+		assert.Equal(t, "delete", op["action"])
+		deleted = append(deleted, op["entity_type"].(string))
+	}
+	assert.Equal(t, []string{
+		"ComponentLocation", "FileComponent",
+		"ComponentLocation", "FileComponent",
+		"Metadata", "ComponentLocation", "SequenceComponent",
+	}, deleted)
+}
@@ -2,6 +2,7 @@ package ftrack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -56,7 +58,11 @@ type Session struct {
 	Schemas           QuerySchemasResult
 	SchemasMap        map[string]map[string]interface{}
 	ServerInformation QueryInformationResult
-	primaryKeysMap    map[string][]string
+	// HttpClient sends the api, upload and download requests. When nil api
+	// requests use a shared client with Timeout and transfers
+	// http.DefaultClient.
+	HttpClient     *http.Client
+	primaryKeysMap map[string][]string
 }
 
 type SessionConfig struct {
@@ -66,6 +72,9 @@ type SessionConfig struct {
 	ApiEndpoint string
 	ClientToken string
 	Timeout     time.Duration
+	// HttpClient is used for all requests instead of the shared clients,
+	// Timeout is not applied to it.
+	HttpClient *http.Client
 }
 
 type callResultWrap struct {
@@ -146,6 +155,7 @@ func NewSession(config SessionConfig) (*Session, error) {
 		ClientToken: config.ClientToken,
 		Timeout:     config.Timeout,
 		Initialized: false,
+		HttpClient:  config.HttpClient,
 	}
 	if err := session.initialize(nil); err != nil {
 		return nil, err
@@ -415,48 +425,103 @@ func (session *Session) Delete(entityType string, id []string) (*DeleteResult, e
 	return &casted, nil
 }
 
+// GetComponentUrl returns a url embedding the api credentials.
+//
+// Deprecated: the api key leaks into logs and browser history, use
+// GetSignedComponentUrl for urls that are shared.
 func (session *Session) GetComponentUrl(componentId uuid.UUID) string {
-	return fmt.Sprintf(
-		"%s/component/get?%s",
-		session.ServerUrl,
-		encodeUriParameters(
-			uriParameter{"id", componentId.String()},
-			uriParameter{"username", session.ApiUser},
-			uriParameter{"apiKey", session.ApiKey},
-		),
-	)
+	return buildUrl(session.ServerUrl, "/component/get", url.Values{
+		"id":       {componentId.String()},
+		"username": {session.ApiUser},
+		"apiKey":   {session.ApiKey},
+	})
 }
 
+// GetThumbnailUrl returns a thumbnail url embedding the api credentials.
+//
+// Deprecated: the api key leaks into logs and browser history, use
+// GetSignedThumbnailUrl for urls that are shared.
 func (session *Session) GetThumbnailUrl(componentId uuid.UUID, size int) string {
-	return fmt.Sprintf(
-		"%s/component/thumbnail?%s",
-		session.ServerUrl,
-		encodeUriParameters(
-			uriParameter{"id", componentId.String()},
-			uriParameter{"size", strconv.Itoa(size)},
-			uriParameter{"username", session.ApiUser},
-			uriParameter{"apiKey", session.ApiKey},
-		),
-	)
+	return buildUrl(session.ServerUrl, "/component/thumbnail", url.Values{
+		"id":       {componentId.String()},
+		"size":     {strconv.Itoa(size)},
+		"username": {session.ApiUser},
+		"apiKey":   {session.ApiKey},
+	})
+}
+
+// GetSignedComponentUrl asks the server for a url to the component that
+// expires after expiry and does not contain any credentials. A zero expiry
+// uses the server default.
+func (session *Session) GetSignedComponentUrl(componentId uuid.UUID, expiry time.Duration) (string, error) {
+	return session.getSignedUrl(NewGetSignedUrlOperation(componentId, expiry))
+}
+
+// GetSignedThumbnailUrl is like GetSignedComponentUrl for the thumbnail of
+// the component scaled to size.
+func (session *Session) GetSignedThumbnailUrl(componentId uuid.UUID, size int, expiry time.Duration) (string, error) {
+	operation := NewGetSignedUrlOperation(componentId, expiry)
+	operation.ThumbnailSize = size
+	return session.getSignedUrl(operation)
+}
+
+func (session *Session) getSignedUrl(operation GetSignedUrlOperation) (string, error) {
+	result, err := session.Call(operation)
+	if err != nil {
+		return "", err
+	}
+	signedUrl := result[0].(GetSignedUrlResult).SignedUrl
+	if signedUrl == "" {
+		return "", errors.New(fmt.Sprintf("server returned no signed url for component %s", operation.ComponentId))
+	}
+	return signedUrl, nil
 }
 
 type CreateComponentOptions struct {
-	Id         *uuid.UUID
+	Id *uuid.UUID
+	// OnProgress receives the cumulative upload percentage.
 	OnProgress *func(int)
-	OnAborted  *func()
-	FileType   *string
-	FileSize   *int64
-	FileName   *string
+	// OnTransferProgress receives cumulative bytes, total, rate and ETA.
+	OnTransferProgress *func(Progress)
+	// ProgressInterval throttles progress callbacks, defaults to
+	// DefaultProgressInterval. The final callback is never throttled.
+	ProgressInterval *time.Duration
+	// OnAborted is called when the context is cancelled before the upload
+	// completes, after the created entities have been removed.
+	OnAborted *func()
+	FileType  *string
+	FileSize  *int64
+	FileName  *string
+	// Location is where the component data is stored, defaults to the
+	// ftrack server location.
+	Location Location
 
 	componentLocationId uuid.UUID
+	containerId         *uuid.UUID
 }
 
-func (options *CreateComponentOptions) setDefaults(file *os.File) error {
+func (options *CreateComponentOptions) setFileDefaults(file *os.File) error {
 	stat, err := file.Stat()
 	if err != nil {
 		return err
 	}
+	if options.FileType == nil {
+		ext := filepath.Ext(NormalizeString(file.Name()))
+		options.FileType = &ext
+	}
+	if options.FileSize == nil {
+		size := stat.Size()
+		options.FileSize = &size
+	}
+	if options.FileName == nil {
+		fileName := NormalizeString(file.Name())
+		base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(file.Name()))
+		options.FileName = &base
+	}
+	return nil
+}
 
+func (options *CreateComponentOptions) setDefaults() {
 	if options.Id == nil {
 		id := uuid.Must(uuid.NewV4(), nil)
 		options.Id = &id
@@ -469,24 +534,34 @@ func (options *CreateComponentOptions) setDefaults(file *os.File) error {
 		onProgress := func(int) {}
 		options.OnProgress = &onProgress
 	}
-
 	if options.FileType == nil {
-		ext := filepath.Ext(NormalizeString(file.Name()))
-		options.FileType = &ext
-	}
-	if options.FileSize == nil {
-		size := stat.Size()
-		options.FileSize = &size
+		fileType := ""
+		options.FileType = &fileType
 	}
 	if options.FileName == nil {
-		fileName := NormalizeString(file.Name())
-		base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(file.Name()))
-		options.FileName = &base
+		fileName := options.Id.String()
+		options.FileName = &fileName
 	}
 	normalizedFileName := NormalizeString(*options.FileName)
 	options.FileName = &normalizedFileName
 	options.componentLocationId = uuid.Must(uuid.NewV4(), nil)
-	return nil
+}
+
+func (options *CreateComponentOptions) componentData() map[string]interface{} {
+	component := map[string]interface{}{
+		"id":        options.Id.String(),
+		"name":      *options.FileName,
+		"size":      *options.FileSize,
+		"file_type": *options.FileType,
+	}
+	if options.containerId != nil {
+		component["container_id"] = options.containerId.String()
+	}
+	return component
+}
+
+func (options *CreateComponentOptions) newProgressTracker() *progressTracker {
+	return newProgressTracker(*options.FileSize, options.ProgressInterval, options.OnProgress, options.OnTransferProgress)
 }
 
 type progressReader struct {
@@ -506,9 +581,21 @@ func (session *Session) CreateComponent(fileName string, options CreateComponent
 		return
 	}
 	defer func() { _ = file.Close() }()
-	if err := options.setDefaults(file); err != nil {
+	if err := options.setFileDefaults(file); err != nil {
 		return nil, err
 	}
+	return session.CreateComponentFromReader(context.Background(), file, *options.FileSize, options)
+}
+
+func (session *Session) CreateComponentFromReader(ctx context.Context, reader io.Reader, size int64, options CreateComponentOptions) (result []CreateResult, err error) {
+	if size < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid component size %d", size))
+	}
+	options.FileSize = &size
+	options.setDefaults()
+	if options.Location != nil && options.Location.Id() != ServerLocationId {
+		return session.createComponentInLocation(ctx, reader, options)
+	}
 	results, err := session.Call(
 		NewGetUploadMetadataOperation(
 			fmt.Sprintf("%s%s", *options.FileName, *options.FileType),
@@ -519,40 +606,71 @@ func (session *Session) CreateComponent(fileName string, options CreateComponent
 		return
 	}
 	uploadMetadata := results[0].(GetUploadMetadataResult)
-	results, err = session.Call(
-		NewCreateOperation("FileComponent", map[string]interface{}{
-			"id":        options.Id.String(),
-			"name":      *options.FileName,
-			"size":      *options.FileSize,
-			"file_type": *options.FileType,
-		}),
-		NewCreateOperation("ComponentLocation", map[string]interface{}{
-			"id":                  options.componentLocationId.String(),
-			"component_id":        options.Id.String(),
-			"location_id":         ServerLocationId,
-			"resource_identifier": options.Id.String(),
-		}),
-	)
+	if err := ctx.Err(); err != nil {
+		(*options.OnAborted)()
+		return nil, err
+	}
+	result, err = session.createComponentEntities(options)
 	if err != nil {
 		return
 	}
-	result = append(result, results[0].(CreateResult))
-	result = append(result, results[1].(CreateResult))
-	client := http.Client{ // TODO: Use singleton?
-		// TODO: Needed? Timeout: session.Timeout,
+	uploadError := session.upload(
+		ctx,
+		uploadMetadata,
+		&progressReader{
+			Reader:   io.LimitReader(reader, size),
+			Reporter: options.newProgressTracker().Add,
+		},
+		size,
+	)
+	if uploadError != nil {
+		session.deleteComponent(options)
+		if ctx.Err() != nil {
+			(*options.OnAborted)()
+			return nil, ctx.Err()
+		}
+		return nil, uploadError
 	}
-	request, err := http.NewRequest(
-		"PUT",
-		uploadMetadata.Url,
+	return
+}
+
+func (session *Session) createComponentInLocation(ctx context.Context, reader io.Reader, options CreateComponentOptions) ([]CreateResult, error) {
+	results, err := session.Call(
+		NewCreateOperation("FileComponent", options.componentData()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	component := results[0].(CreateResult)
+	location, err := session.AddComponent(
+		ctx,
+		component.Data,
+		options.Location,
 		&progressReader{
-			Reader: file,
-			Reporter: func(c int64) {
-				(*options.OnProgress)(int(float32(c) / float32(*options.FileSize) * 100))
-			},
+			Reader:   io.LimitReader(reader, *options.FileSize),
+			Reporter: options.newProgressTracker().Add,
 		},
+		*options.FileSize,
 	)
 	if err != nil {
-		return
+		_, _ = session.Call(NewDeleteOperation("FileComponent", []string{options.Id.String()}))
+		if ctx.Err() != nil {
+			(*options.OnAborted)()
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return []CreateResult{component, *location}, nil
+}
+
+func (session *Session) upload(ctx context.Context, uploadMetadata GetUploadMetadataResult, reader io.Reader, size int64) error {
+	request, err := http.NewRequestWithContext(ctx, "PUT", uploadMetadata.Url, reader)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	if size == 0 {
+		request.Body = http.NoBody
 	}
 	for k, v := range uploadMetadata.Headers {
 		if k == "Content-Length" {
@@ -560,29 +678,58 @@ func (session *Session) CreateComponent(fileName string, options CreateComponent
 		}
 		request.Header.Set(k, v)
 	}
-	response, err := client.Do(request)
-	if err == nil {
-		defer response.Body.Close()
+	response, err := session.transferClient().Do(request)
+	if err != nil {
+		return err
 	}
+	defer response.Body.Close()
 	// TODO: add UploadError type?
-	var uploadError = err
-	if err == nil && response.StatusCode != http.StatusOK {
-		text := response.Status
-		body, err := ioutil.ReadAll(response.Body)
-		if err == nil {
-			text += "\n"
-			text += string(body)
-		}
-		uploadError = errors.New(text)
+	if response.StatusCode != http.StatusOK {
+		return newResponseError(response)
 	}
-	if uploadError != nil {
-		_, _ = session.Call(
-			NewDeleteOperation("FileComponent", []string{options.Id.String()}),
-			NewDeleteOperation("ComponentLocation", []string{options.componentLocationId.String()}),
-		)
-		return nil, uploadError
+	return nil
+}
+
+// transferClient sends the upload and download requests, which can take longer
+// than Timeout and are cancelled with their context instead.
+func (session *Session) transferClient() *http.Client {
+	if session.HttpClient != nil {
+		return session.HttpClient
 	}
-	return
+	return http.DefaultClient
+}
+
+func (session *Session) createComponentEntities(options CreateComponentOptions) ([]CreateResult, error) {
+	results, err := session.Call(
+		NewCreateOperation("FileComponent", options.componentData()),
+		NewCreateOperation("ComponentLocation", map[string]interface{}{
+			"id":                  options.componentLocationId.String(),
+			"component_id":        options.Id.String(),
+			"location_id":         ServerLocationId,
+			"resource_identifier": options.Id.String(),
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []CreateResult{results[0].(CreateResult), results[1].(CreateResult)}, nil
+}
+
+func newResponseError(response *http.Response) error {
+	text := response.Status
+	body, err := ioutil.ReadAll(response.Body)
+	if err == nil {
+		text += "\n"
+		text += string(body)
+	}
+	return errors.New(text)
+}
+
+func (session *Session) deleteComponent(options CreateComponentOptions) {
+	_, _ = session.Call(
+		NewDeleteOperation("FileComponent", []string{options.Id.String()}),
+		NewDeleteOperation("ComponentLocation", []string{options.componentLocationId.String()}),
+	)
 }
 
 func (session *Session) getServerError(response []byte) error {
@@ -630,7 +777,11 @@ func (session *Session) call(operations ...interface{}) ([]byte, error) {
 	request.Header.Set("ftrack-user", session.ApiUser)
 	request.Header.Set("ftrack-Clienttoken", session.ClientToken)
 
-	resp, err := newNetClient(session.Timeout).Do(request)
+	client := session.HttpClient
+	if client == nil {
+		client = newNetClient(session.Timeout)
+	}
+	resp, err := client.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -1,8 +1,11 @@
 package ftrack
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/ftracktest"
 	"github.com/go-shadow/moment"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
@@ -10,7 +13,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func mustEnvLookUp(t *testing.T, key string) string {
@@ -44,13 +51,95 @@ func mustDeepGet(t *testing.T, data interface{}, path ...string) interface{} {
 	return value
 }
 
+var recorders sync.Map
+
+// testServerConfig starts an ftracktest.Server with a task and the versions of
+// an asset for the tests to query.
+func testServerConfig(t *testing.T) SessionConfig {
+	server, err := ftracktest.NewServer(ftracktest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	var stateId interface{}
+	for _, state := range server.Store.Entities("State") {
+		if state["short"] == "NOT_STARTED" {
+			stateId = state["id"]
+		}
+	}
+	add := func(entityType string, data map[string]interface{}) interface{} {
+		entity, err := server.Store.Add(entityType, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entity["id"]
+	}
+	statusId := add("Status", map[string]interface{}{"name": "Not started", "state_id": stateId})
+	projectId := add("Project", map[string]interface{}{"name": "test", "full_name": "Test"})
+	taskId := add("Task", map[string]interface{}{"name": "compositing", "parent_id": projectId, "project_id": projectId, "status_id": statusId})
+	typeId := add("AssetType", map[string]interface{}{"name": "Geometry", "short": "geo"})
+	assetId := add("Asset", map[string]interface{}{"name": "model", "context_id": taskId, "type_id": typeId})
+	for version := 1; version <= 2; version++ {
+		add("AssetVersion", map[string]interface{}{"version": version, "asset_id": assetId, "task_id": taskId})
+	}
+	return SessionConfig{ApiKey: server.ApiKey, ApiUser: server.ApiUser, ServerUrl: server.URL}
+}
+
+// sessionConfig returns the config of the server in the environment. With
+// FTRACK_RECORD set the requests of the test are recorded to
+// testdata/<test>.json, without FTRACK_SERVER they are replayed from it when
+// it exists and sent to an ftracktest.Server otherwise.
+func sessionConfig(t *testing.T) SessionConfig {
+	path := filepath.Join("testdata", strings.ReplaceAll(t.Name(), "/", "_")+".json")
+	_, record := os.LookupEnv("FTRACK_RECORD")
+	_, live := os.LookupEnv("FTRACK_SERVER")
+	_, statErr := os.Stat(path)
+	if !record && !live && statErr != nil {
+		return testServerConfig(t)
+	}
+	if !record && live {
+		return SessionConfig{
+			ApiKey:    mustEnvLookUp(t, "FTRACK_API_KEY"),
+			ApiUser:   mustEnvLookUp(t, "FTRACK_API_USER"),
+			ServerUrl: mustEnvLookUp(t, "FTRACK_SERVER"),
+		}
+	}
+	recorder, ok := recorders.Load(t.Name())
+	if !ok {
+		mode := ftracktest.Replay
+		if record {
+			mode = ftracktest.Record
+		}
+		created, err := ftracktest.NewRecorder(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder = created
+		recorders.Store(t.Name(), created)
+		t.Cleanup(func() {
+			recorders.Delete(t.Name())
+			if err := created.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	config := SessionConfig{
+		ApiKey:     ftracktest.Redacted,
+		ApiUser:    "replay",
+		ServerUrl:  "http://replay.invalid",
+		HttpClient: recorder.(*ftracktest.Recorder).Client(),
+	}
+	if record {
+		config.ApiKey = mustEnvLookUp(t, "FTRACK_API_KEY")
+		config.ApiUser = mustEnvLookUp(t, "FTRACK_API_USER")
+		config.ServerUrl = mustEnvLookUp(t, "FTRACK_SERVER")
+	}
+	return config
+}
+
 func freshSession(t *testing.T) *Session {
 
-	s, err := NewSession(SessionConfig{
-		ApiKey:    mustEnvLookUp(t, "FTRACK_API_KEY"),
-		ApiUser:   mustEnvLookUp(t, "FTRACK_API_USER"),
-		ServerUrl: mustEnvLookUp(t, "FTRACK_SERVER"),
-	})
+	s, err := NewSession(sessionConfig(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -62,11 +151,9 @@ func TestSession_Initialized(t *testing.T) {
 		freshSession(t).Initialized, true,
 		"Should initialize the session automatically",
 	)
-	_, err := NewSession(SessionConfig{
-		ApiKey:    "INVALID_API_KEY",
-		ApiUser:   mustEnvLookUp(t, "FTRACK_API_USER"),
-		ServerUrl: mustEnvLookUp(t, "FTRACK_SERVER"),
-	})
+	config := sessionConfig(t)
+	config.ApiKey = "INVALID_API_KEY"
+	_, err := NewSession(config)
 	switch err.(type) {
 	default:
 		t.Fatal("Should reject invalid credentials with ServerError")
@@ -455,3 +542,100 @@ func TestSession_AsyncQuery(t *testing.T) {
 		assert.Nil(t, r.Err)
 	}
 }
+
+func TestSession_CreateComponentFromReader(t *testing.T) {
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	content := []byte("rendered frame content")
+	fileName, fileType := "frame", ".exr"
+	create, err := session.CreateComponentFromReader(
+		context.Background(),
+		bytes.NewReader(content),
+		int64(len(content)),
+		CreateComponentOptions{FileName: &fileName, FileType: &fileType},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, create, 2)
+	id := create[0].Data["id"].(string)
+	assert.Equal(t, "frame", create[0].Data["name"])
+	assert.Equal(t, id, create[1].Data["component_id"])
+	uploaded, ok := server.File(id)
+	assert.True(t, ok, "Should upload the component")
+	assert.Equal(t, content, uploaded)
+}
+
+func TestSession_CreateComponentFromReaderCleanup(t *testing.T) {
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if op["action"] == "get_upload_metadata" {
+				results = append(results, map[string]interface{}{"url": "http://127.0.0.1:0/upload"})
+				continue
+			}
+			results = append(results, map[string]interface{}{"action": op["action"], "data": op["entity_data"]})
+		}
+		return results
+	})
+	_, err := session.CreateComponentFromReader(context.Background(), strings.NewReader("data"), 4, CreateComponentOptions{})
+	assert.NotNil(t, err)
+	batches := server.Batches()
+	cleanup := batches[len(batches)-1]
+	assert.Len(t, cleanup, 2)
+	assert.Equal(t, "delete", cleanup[0]["action"])
+	assert.Equal(t, "FileComponent", cleanup[0]["entity_type"])
+	assert.Equal(t, "ComponentLocation", cleanup[1]["entity_type"])
+}
+
+type cancellingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return 0, context.Canceled
+}
+
+func TestSession_CreateComponentFromReaderAborted(t *testing.T) {
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	aborted := false
+	onAborted := func() { aborted = true }
+	_, err := session.CreateComponentFromReader(ctx, &cancellingReader{cancel}, 10, CreateComponentOptions{OnAborted: &onAborted})
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, aborted, "Should call OnAborted")
+	batches := server.Batches()
+	cleanup := batches[len(batches)-1]
+	assert.Equal(t, "delete", cleanup[0]["action"])
+}
+
+func TestSession_GetSignedComponentUrl(t *testing.T) {
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{"signed_url": "https://storage.example.com/component?signature=abc"}}
+	})
+	componentId := uuid.Must(uuid.NewV4(), nil)
+	signedUrl, err := session.GetSignedComponentUrl(componentId, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://storage.example.com/component?signature=abc", signedUrl)
+	_, err = session.GetSignedThumbnailUrl(componentId, 300, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := server.Batches()
+	assert.Equal(t, "get_signed_url", batches[0][0]["action"])
+	assert.Equal(t, componentId.String(), batches[0][0]["component_id"])
+	assert.Equal(t, float64(3600), batches[0][0]["expires_in"])
+	assert.Equal(t, float64(300), batches[1][0]["thumbnail_size"])
+	_, ok := batches[1][0]["expires_in"]
+	assert.False(t, ok, "Should use the server default expiry")
+	assert.NotContains(t, signedUrl, session.ApiKey)
+}
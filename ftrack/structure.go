@@ -0,0 +1,249 @@
+package ftrack
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// EntityIdStructure uses the component id as resource identifier, as the
+// ftrack server location does.
+type EntityIdStructure struct{}
+
+func (structure *EntityIdStructure) GetResourceIdentifier(session *Session, component map[string]interface{}, source string) (string, error) {
+	return componentId(component)
+}
+
+// OriginStructure uses the path of the source data as resource identifier.
+type OriginStructure struct{}
+
+func (structure *OriginStructure) GetResourceIdentifier(session *Session, component map[string]interface{}, source string) (string, error) {
+	if source == "" {
+		return "", errors.New("no source path to use as resource identifier")
+	}
+	return source, nil
+}
+
+// IdStructure places components in directories derived from their id, e.g.
+// "d/3/4/e/d34e...-....exr". Sequence members are placed next to their
+// container using its sequence expression.
+type IdStructure struct{}
+
+func (structure *IdStructure) GetResourceIdentifier(session *Session, component map[string]interface{}, source string) (string, error) {
+	component, err := populateComponent(session, component)
+	if err != nil {
+		return "", err
+	}
+	id, err := componentId(component)
+	if err != nil {
+		return "", err
+	}
+	fileType, _ := component["file_type"].(string)
+	entityType, _ := GetEntityType(component)
+	container, _ := component["container"].(map[string]interface{})
+	switch {
+	case entityType == "SequenceComponent":
+		return path.Join(idParts(id)+"/"+id, sequenceExpression(component)+fileType), nil
+	case entityType == "ContainerComponent":
+		return path.Join(idParts(id), id), nil
+	case container != nil:
+		containerPath, err := structure.GetResourceIdentifier(session, container, "")
+		if err != nil {
+			return "", err
+		}
+		name, _ := component["name"].(string)
+		if containerType, _ := GetEntityType(container); containerType == "SequenceComponent" {
+			if frame, err := strconv.Atoi(name); err == nil {
+				name = fmt.Sprintf(sequenceExpression(container), frame)
+			}
+			return path.Join(path.Dir(containerPath), SanitiseForFilesystem(name+fileType)), nil
+		}
+		return path.Join(containerPath, SanitiseForFilesystem(name+fileType)), nil
+	default:
+		return path.Join(idParts(id), id+fileType), nil
+	}
+}
+
+func idParts(id string) string {
+	var parts []string
+	for i, c := range id {
+		if i >= 4 {
+			break
+		}
+		parts = append(parts, string(c))
+	}
+	return strings.Join(parts, "/")
+}
+
+func sequenceExpression(container map[string]interface{}) string {
+	padding, _ := toInt64(container["padding"])
+	if padding > 0 {
+		return fmt.Sprintf("%%0%dd", padding)
+	}
+	return "%d"
+}
+
+// populateComponent makes sure the attributes structures rely on are loaded,
+// including the container of sequence and container members.
+func populateComponent(session *Session, component map[string]interface{}) (map[string]interface{}, error) {
+	keys := []string{"name", "file_type", "container_id"}
+	entityType, _ := GetEntityType(component)
+	if entityType == "SequenceComponent" {
+		keys = append(keys, "padding")
+	}
+	var missing []string
+	for _, key := range keys {
+		if _, ok := component[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		populated, err := session.EnsurePopulated(component, missing)
+		if err != nil {
+			return nil, err
+		}
+		component = populated
+	}
+	containerId, _ := component["container_id"].(string)
+	if _, ok := component["container"].(map[string]interface{}); ok || containerId == "" {
+		return component, nil
+	}
+	result, err := session.Query(fmt.Sprintf(
+		"select id, name, file_type, container_id from Component where id is %s", containerId,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.New(fmt.Sprintf("container %s of component not found", containerId))
+	}
+	container, err := populateComponent(session, result.Data[0])
+	if err != nil {
+		return nil, err
+	}
+	populated := make(map[string]interface{}, len(component)+1)
+	for key, value := range component {
+		populated[key] = value
+	}
+	populated["container"] = container
+	return populated, nil
+}
+
+// SanitiseForFilesystem replaces characters that are unsafe in file names.
+// Empty names and the "." and ".." directory names become "_" so that they
+// can't collapse the path into a parent directory.
+func SanitiseForFilesystem(value string) string {
+	value = NormalizeString(value)
+	if value == "" || value == "." || value == ".." {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 32 {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// StandardStructure places components below the context hierarchy of their
+// version, e.g. "project/sq010/sh020/compositing/beauty/v003/main.exr". The
+// ancestors come from the link of the asset parent, followed by the task of
+// the version when set. Sequence components get a directory of their own
+// named after the container, e.g. ".../v003/main/main.%04d.exr".
+type StandardStructure struct{}
+
+func (structure *StandardStructure) GetResourceIdentifier(session *Session, component map[string]interface{}, source string) (string, error) {
+	component, err := populateComponent(session, component)
+	if err != nil {
+		return "", err
+	}
+	entityType, _ := GetEntityType(component)
+	name, _ := component["name"].(string)
+	fileType, _ := component["file_type"].(string)
+	container, _ := component["container"].(map[string]interface{})
+	versioned := component
+	if container != nil {
+		versioned = container
+	}
+	versionPath, err := structure.getVersionPath(session, versioned)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case entityType == "SequenceComponent":
+		name = SanitiseForFilesystem(name)
+		return path.Join(versionPath, name, name+"."+sequenceExpression(component)+fileType), nil
+	case entityType == "ContainerComponent":
+		return path.Join(versionPath, SanitiseForFilesystem(name)), nil
+	case container != nil:
+		containerName, _ := container["name"].(string)
+		containerName = SanitiseForFilesystem(containerName)
+		if containerType, _ := GetEntityType(container); containerType == "SequenceComponent" {
+			if frame, err := strconv.Atoi(name); err == nil {
+				name = fmt.Sprintf(sequenceExpression(container), frame)
+			}
+			return path.Join(versionPath, containerName, containerName+"."+SanitiseForFilesystem(name+fileType)), nil
+		}
+		return path.Join(versionPath, containerName, SanitiseForFilesystem(name+fileType)), nil
+	default:
+		return path.Join(versionPath, SanitiseForFilesystem(name+fileType)), nil
+	}
+}
+
+func (structure *StandardStructure) getVersionPath(session *Session, component map[string]interface{}) (string, error) {
+	id, err := componentId(component)
+	if err != nil {
+		return "", err
+	}
+	version, _ := component["version"].(map[string]interface{})
+	if !hasVersionHierarchy(version) {
+		result, err := session.Query(fmt.Sprintf(
+			"select version.version, version.asset.name, version.asset.parent.link, version.task.name from Component where id is %s",
+			id,
+		))
+		if err != nil {
+			return "", err
+		}
+		if len(result.Data) == 0 {
+			return "", errors.New(fmt.Sprintf("component %s not found", id))
+		}
+		version, _ = result.Data[0]["version"].(map[string]interface{})
+	}
+	if version == nil {
+		return "", errors.New(fmt.Sprintf("component %s must belong to a version to use StandardStructure", id))
+	}
+	asset, _ := version["asset"].(map[string]interface{})
+	parent, _ := asset["parent"].(map[string]interface{})
+	link, _ := parent["link"].([]interface{})
+	if len(link) == 0 {
+		return "", errors.New(fmt.Sprintf("failed to resolve hierarchy of component %s", id))
+	}
+	var parts []string
+	for _, item := range link {
+		casted, _ := item.(map[string]interface{})
+		name, _ := casted["name"].(string)
+		parts = append(parts, SanitiseForFilesystem(name))
+	}
+	if task, ok := version["task"].(map[string]interface{}); ok {
+		if name, ok := task["name"].(string); ok {
+			parts = append(parts, SanitiseForFilesystem(name))
+		}
+	}
+	assetName, _ := asset["name"].(string)
+	number, _ := toInt64(version["version"])
+	parts = append(parts, SanitiseForFilesystem(assetName), fmt.Sprintf("v%03d", number))
+	return path.Join(parts...), nil
+}
+
+func hasVersionHierarchy(version map[string]interface{}) bool {
+	asset, _ := version["asset"].(map[string]interface{})
+	parent, _ := asset["parent"].(map[string]interface{})
+	_, ok := parent["link"].([]interface{})
+	return ok && version["version"] != nil
+}
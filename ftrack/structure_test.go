@@ -0,0 +1,201 @@
+package ftrack
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdStructure(t *testing.T) {
+	structure := &IdStructure{}
+	component := map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "d34e6a0c-0000-0000-0000-000000000000",
+		"name":         "beauty",
+		"file_type":    ".exr",
+		"container_id": nil,
+	}
+	resourceIdentifier, err := structure.GetResourceIdentifier(nil, component, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "d/3/4/e/d34e6a0c-0000-0000-0000-000000000000.exr", resourceIdentifier)
+
+	member := map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "11111111-0000-0000-0000-000000000000",
+		"name":         "12",
+		"file_type":    ".exr",
+		"container_id": "a0000000-0000-0000-0000-000000000000",
+		"container": map[string]interface{}{
+			EntityTypeKey:  "SequenceComponent",
+			"id":           "a0000000-0000-0000-0000-000000000000",
+			"name":         "beauty",
+			"file_type":    ".exr",
+			"padding":      4,
+			"container_id": nil,
+		},
+	}
+	resourceIdentifier, err = structure.GetResourceIdentifier(nil, member, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a/0/0/0/a0000000-0000-0000-0000-000000000000/0012.exr", resourceIdentifier)
+}
+
+func standardComponent() map[string]interface{} {
+	return map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "22222222-0000-0000-0000-000000000000",
+		"name":         "main",
+		"file_type":    ".exr",
+		"container_id": nil,
+		"version": map[string]interface{}{
+			"version": 3,
+			"task":    map[string]interface{}{"name": "Compositing"},
+			"asset": map[string]interface{}{
+				"name": "beauty",
+				"parent": map[string]interface{}{
+					"link": []interface{}{
+						map[string]interface{}{"type": "Project", "name": "big_buck"},
+						map[string]interface{}{"type": "TypedContext", "name": "sq010"},
+						map[string]interface{}{"type": "TypedContext", "name": "sh020"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStandardStructure(t *testing.T) {
+	structure := &StandardStructure{}
+	component := standardComponent()
+	resourceIdentifier, err := structure.GetResourceIdentifier(nil, component, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "big_buck/sq010/sh020/Compositing/beauty/v003/main.exr", resourceIdentifier)
+
+	container := standardComponent()
+	container[EntityTypeKey] = "SequenceComponent"
+	container["padding"] = 4
+	container["file_type"] = ".dpx"
+	resourceIdentifier, err = structure.GetResourceIdentifier(nil, container, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "big_buck/sq010/sh020/Compositing/beauty/v003/main/main.%04d.dpx", resourceIdentifier)
+
+	member := map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "33333333-0000-0000-0000-000000000000",
+		"name":         "1001",
+		"file_type":    ".dpx",
+		"container_id": container["id"],
+		"container":    container,
+	}
+	resourceIdentifier, err = structure.GetResourceIdentifier(nil, member, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "big_buck/sq010/sh020/Compositing/beauty/v003/main/main.1001.dpx", resourceIdentifier)
+}
+
+func TestStandardStructure_DotNames(t *testing.T) {
+	structure := &StandardStructure{}
+	component := standardComponent()
+	component["name"] = ".."
+	component["file_type"] = ""
+	asset := component["version"].(map[string]interface{})["asset"].(map[string]interface{})
+	asset["name"] = "."
+	link := asset["parent"].(map[string]interface{})["link"].([]interface{})
+	link[2].(map[string]interface{})["name"] = ".."
+	resourceIdentifier, err := structure.GetResourceIdentifier(nil, component, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "big_buck/sq010/_/Compositing/_/v003/_", resourceIdentifier)
+}
+
+func TestIdStructure_QueriedContainer(t *testing.T) {
+	session, _ := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		return []interface{}{map[string]interface{}{
+			"action": "query",
+			"data": []interface{}{map[string]interface{}{
+				EntityTypeKey:  "ContainerComponent",
+				"id":           "b0000000-0000-0000-0000-000000000000",
+				"name":         "bundle",
+				"file_type":    "",
+				"container_id": nil,
+			}},
+		}}
+	})
+	member := map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "11111111-0000-0000-0000-000000000000",
+		"name":         "notes",
+		"file_type":    ".txt",
+		"container_id": "b0000000-0000-0000-0000-000000000000",
+	}
+	resourceIdentifier, err := (&IdStructure{}).GetResourceIdentifier(session, member, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "b/0/0/0/b0000000-0000-0000-0000-000000000000/notes.txt", resourceIdentifier)
+	_, ok := member["container"]
+	assert.False(t, ok)
+}
+
+func TestDiskAccessor_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ftrack-accessor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	accessor := &DiskAccessor{Prefix: dir}
+	err = accessor.Write(context.Background(), "a/b/file.txt", strings.NewReader("content"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := accessor.Exists(context.Background(), "a/b/file.txt")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "a", "b", "file.txt"))
+	assert.Equal(t, "content", string(content))
+
+	err = accessor.Write(context.Background(), "a/b/short.txt", strings.NewReader("short"), 10)
+	assert.NotNil(t, err, "Should reject size mismatch")
+	entries, _ := ioutil.ReadDir(filepath.Join(dir, "a", "b"))
+	assert.Len(t, entries, 1, "Should not leave partial files behind")
+}
+
+func TestDiskAccessor_GetFilesystemPath(t *testing.T) {
+	prefix := filepath.Join(os.TempDir(), "ftrack-accessor")
+	accessor := &DiskAccessor{Prefix: prefix}
+	path, err := accessor.GetFilesystemPath("a/../b/file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(prefix, "b", "file.txt"), path)
+	for _, resourceIdentifier := range []string{
+		"../file.txt",
+		"a/../../file.txt",
+		"a/..",
+		"../ftrack-accessor-other/file.txt",
+		filepath.Join(prefix, "file.txt"),
+		"/etc/passwd",
+	} {
+		_, err := accessor.GetFilesystemPath(resourceIdentifier)
+		assert.NotNil(t, err, "Should reject %s", resourceIdentifier)
+	}
+	err = accessor.Write(context.Background(), "../escaped.txt", strings.NewReader("content"), 7)
+	assert.NotNil(t, err)
+	_, err = os.Stat(filepath.Join(os.TempDir(), "escaped.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	path, err = (&DiskAccessor{}).GetFilesystemPath("/mnt/projects/file.txt")
+	assert.Nil(t, err, "Should accept any path without a prefix")
+	assert.Equal(t, filepath.FromSlash("/mnt/projects/file.txt"), path)
+}
@@ -0,0 +1,208 @@
+package ftrack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const DefaultThumbnailQuality int = 90
+
+type CreateThumbnailOptions struct {
+	// MaxSize downscales images whose width or height exceeds it, keeping the
+	// aspect ratio. Zero keeps the original image.
+	MaxSize int
+	// Quality of downscaled JPEG images, defaults to DefaultThumbnailQuality.
+	Quality  int
+	FileName *string
+	// FileType such as ".jpg" is detected from the image when nil.
+	FileType *string
+}
+
+// CreateThumbnail uploads the image at fileName to the server location and
+// sets it as thumbnail of entity.
+func (session *Session) CreateThumbnail(ctx context.Context, entity map[string]interface{}, fileName string, options CreateThumbnailOptions) (*UpdateResult, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if options.FileName == nil {
+		base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		options.FileName = &base
+	}
+	if options.FileType == nil {
+		fileType := strings.ToLower(filepath.Ext(fileName))
+		options.FileType = &fileType
+	}
+	return session.CreateThumbnailFromReader(ctx, entity, bytes.NewReader(content), options)
+}
+
+func (session *Session) CreateThumbnailFromReader(ctx context.Context, entity map[string]interface{}, reader io.Reader, options CreateThumbnailOptions) (*UpdateResult, error) {
+	entityType, err := GetEntityType(entity)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, pk := range session.GetPrimaryKeyAttributes(entityType) {
+		value, ok := entity[pk]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("entity is missing primary key %s", pk))
+		}
+		keys = append(keys, fmt.Sprint(value))
+	}
+	if len(keys) == 0 {
+		return nil, errors.New(fmt.Sprintf("could't determine primary keys for entity type %s", entityType))
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	fileType := ""
+	if options.FileType != nil {
+		fileType = *options.FileType
+	}
+	if options.MaxSize > 0 {
+		var format string
+		content, format, err = downscaleImage(content, options)
+		if err != nil {
+			return nil, err
+		}
+		fileType = formatFileType(format)
+	} else if options.FileType == nil {
+		// Images that are not scaled are uploaded as they are, whatever
+		// their format.
+		if _, format, err := image.DecodeConfig(bytes.NewReader(content)); err == nil {
+			fileType = formatFileType(format)
+		}
+	}
+	fileName := "thumbnail"
+	if options.FileName != nil {
+		fileName = *options.FileName
+	}
+	create, err := session.CreateComponentFromReader(ctx, bytes.NewReader(content), int64(len(content)), CreateComponentOptions{
+		FileName: &fileName,
+		FileType: &fileType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	thumbnailId := create[0].Data["id"]
+	update, err := session.Update(entityType, keys, map[string]interface{}{"thumbnail_id": thumbnailId})
+	if err != nil {
+		_, _ = session.Call(
+			NewDeleteOperation("FileComponent", []string{fmt.Sprint(thumbnailId)}),
+			NewDeleteOperation("ComponentLocation", []string{fmt.Sprint(create[1].Data["id"])}),
+		)
+		return nil, err
+	}
+	return update, nil
+}
+
+func formatFileType(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// downscaleImage returns content scaled down to fit within a positive MaxSize
+// together with its format. Images already small enough are returned
+// unchanged.
+func downscaleImage(content []byte, options CreateThumbnailOptions) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", errors.New(fmt.Sprintf("unsupported thumbnail image: %s", err))
+	}
+	if config.Width <= options.MaxSize && config.Height <= options.MaxSize {
+		return content, format, nil
+	}
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", err
+	}
+	width, height := options.MaxSize, options.MaxSize
+	if config.Width >= config.Height {
+		height = config.Height * options.MaxSize / config.Width
+	} else {
+		width = config.Width * options.MaxSize / config.Height
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	scaled := resizeImage(source, width, height)
+	var buffer bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buffer, scaled)
+	case "gif":
+		err = gif.Encode(&buffer, scaled, nil)
+	default:
+		quality := options.Quality
+		if quality <= 0 {
+			quality = DefaultThumbnailQuality
+		}
+		format = "jpeg"
+		err = jpeg.Encode(&buffer, scaled, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), format, nil
+}
+
+// resizeImage downscales source by averaging the source pixels covered by
+// each destination pixel.
+func resizeImage(source image.Image, width int, height int) *image.NRGBA {
+	bounds := source.Bounds()
+	rgba := image.NewNRGBA(bounds)
+	draw.Draw(rgba, bounds, source, bounds.Min, draw.Src)
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sourceWidth, sourceHeight := bounds.Dx(), bounds.Dy()
+	for y := 0; y < height; y++ {
+		y0 := y * sourceHeight / height
+		y1 := (y + 1) * sourceHeight / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * sourceWidth / width
+			x1 := (x + 1) * sourceWidth / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					offset := rgba.PixOffset(bounds.Min.X+sx, bounds.Min.Y+sy)
+					pixel := rgba.Pix[offset : offset+4]
+					r += uint64(pixel[0])
+					g += uint64(pixel[1])
+					b += uint64(pixel[2])
+					a += uint64(pixel[3])
+					n++
+				}
+			}
+			scaled.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(b / n),
+				A: uint8(a / n),
+			})
+		}
+	}
+	return scaled
+}
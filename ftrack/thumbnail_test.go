@@ -0,0 +1,99 @@
+package ftrack
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestSession_CreateThumbnailFromReader(t *testing.T) {
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	source := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			source.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, source); err != nil {
+		t.Fatal(err)
+	}
+	task := map[string]interface{}{EntityTypeKey: "Task", "id": "task-1"}
+	update, err := session.CreateThumbnailFromReader(context.Background(), task, &encoded, CreateThumbnailOptions{MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnailId := update.Data["thumbnail_id"].(string)
+	uploaded, ok := server.File(thumbnailId)
+	assert.True(t, ok, "Should upload the thumbnail")
+	decoded, format, err := image.Decode(bytes.NewReader(uploaded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "png", format)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), decoded.Bounds())
+	r, g, b, a := decoded.At(50, 25).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0, 0, 0xffff}, []uint32{r, g, b, a})
+
+	batches := server.Batches()
+	updateOp := batches[len(batches)-1][0]
+	assert.Equal(t, "update", updateOp["action"])
+	assert.Equal(t, []interface{}{"task-1"}, updateOp["entity_key"])
+	create := batches[1][0]["entity_data"].(map[string]interface{})
+	assert.Equal(t, ".png", create["file_type"])
+}
+
+func TestSession_CreateThumbnailFromReaderUnscaled(t *testing.T) {
+	session, server := newMockSession(t, nil)
+	server.response = server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	content := []byte("RIFF\x00\x00\x00\x00WEBPVP8 not a registered format")
+	fileType := ".webp"
+	task := map[string]interface{}{EntityTypeKey: "Task", "id": "task-1"}
+	update, err := session.CreateThumbnailFromReader(context.Background(), task, bytes.NewReader(content), CreateThumbnailOptions{FileType: &fileType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploaded, ok := server.File(update.Data["thumbnail_id"].(string))
+	assert.True(t, ok, "Should upload the thumbnail")
+	assert.Equal(t, content, uploaded)
+	create := server.Batches()[1][0]["entity_data"].(map[string]interface{})
+	assert.Equal(t, ".webp", create["file_type"])
+}
+
+func TestSession_CreateThumbnailFromReaderFailedUpdate(t *testing.T) {
+	session, server := newMockSession(t, nil)
+	respond := server.uploadMetadataResponse(func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"action": op["action"], "data": op["entity_data"]}
+	})
+	server.response = func(operations []map[string]interface{}) interface{} {
+		if operations[0]["action"] == "update" {
+			return map[string]interface{}{
+				"exception":  "ServerError",
+				"content":    "Task task-1 not found",
+				"error_code": 0,
+			}
+		}
+		return respond(operations)
+	}
+	task := map[string]interface{}{EntityTypeKey: "Task", "id": "task-1"}
+	_, err := session.CreateThumbnailFromReader(context.Background(), task, bytes.NewReader([]byte("thumbnail")), CreateThumbnailOptions{})
+	assert.Error(t, err)
+
+	batches := server.Batches()
+	deleteBatch := batches[len(batches)-1]
+	if assert.Len(t, deleteBatch, 2) {
+		assert.Equal(t, "delete", deleteBatch[0]["action"])
+		assert.Equal(t, "FileComponent", deleteBatch[0]["entity_type"])
+		assert.Equal(t, "delete", deleteBatch[1]["action"])
+		assert.Equal(t, "ComponentLocation", deleteBatch[1]["entity_type"])
+	}
+}
@@ -0,0 +1,228 @@
+package ftrack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type TransferComponentsOptions struct {
+	Concurrency *int
+	// OnProgress and OnTransferProgress report progress across all components.
+	OnProgress         *func(int)
+	OnTransferProgress *func(Progress)
+	ProgressInterval   *time.Duration
+}
+
+func (options *TransferComponentsOptions) setDefaults() {
+	if options.Concurrency == nil {
+		concurrency := DefaultMultipartConcurrency
+		options.Concurrency = &concurrency
+	}
+}
+
+type transferItem struct {
+	component          map[string]interface{}
+	resourceIdentifier string
+	size               int64
+	index              int
+}
+
+// TransferComponents copies components from source to target and registers
+// them in target. Containers are transferred together with their members.
+// Components already fully available in target are skipped. The results hold
+// the ComponentLocation created in target for each component, nil when it was
+// skipped or not transferred because of an error.
+func (session *Session) TransferComponents(ctx context.Context, components []map[string]interface{}, source Location, target Location, options TransferComponentsOptions) ([]*CreateResult, error) {
+	options.setDefaults()
+	if !target.Managed() {
+		return nil, errors.New(fmt.Sprintf("cannot transfer data to unmanaged location %s", target.Name()))
+	}
+	results := make([]*CreateResult, len(components))
+	availability, err := session.GetComponentAvailability(components, []Location{target})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	var pending []map[string]interface{}
+	var indices []int
+	for i, component := range components {
+		id, _ := componentId(component)
+		if availability[id][target.Id()] == 100 {
+			continue
+		}
+		ids = append(ids, id)
+		pending = append(pending, component)
+		indices = append(indices, i)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	members, err := session.queryInBatches("select id, name, file_type, size, container_id from Component where container_id in (%s)", ids)
+	if err != nil {
+		return nil, err
+	}
+	sized, err := session.queryInBatches("select id, size from Component where id in (%s)", ids)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, component := range sized {
+		id, _ := component["id"].(string)
+		sizes[id], _ = toInt64(component["size"])
+	}
+	hasMembers := map[string]bool{}
+	var dataIds []string
+	for _, member := range members {
+		id, _ := member["id"].(string)
+		containerId, _ := member["container_id"].(string)
+		hasMembers[containerId] = true
+		sizes[id], _ = toInt64(member["size"])
+		dataIds = append(dataIds, id)
+	}
+	for _, id := range ids {
+		if !hasMembers[id] {
+			dataIds = append(dataIds, id)
+		}
+	}
+	resourceIdentifiers := map[string]string{}
+	componentLocations, err := session.queryInBatches(
+		fmt.Sprintf("select component_id, resource_identifier from ComponentLocation where location_id is \"%s\" and component_id in (%%s)", source.Id()),
+		dataIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, componentLocation := range componentLocations {
+		id, _ := componentLocation["component_id"].(string)
+		resourceIdentifiers[id], _ = componentLocation["resource_identifier"].(string)
+	}
+
+	var containerIds []string
+	for _, id := range ids {
+		if hasMembers[id] {
+			containerIds = append(containerIds, id)
+		}
+	}
+	transferred := map[string]bool{}
+	targetLocations, err := session.queryInBatches(
+		fmt.Sprintf("select component_id from ComponentLocation where location_id is \"%s\" and component_id in (%%s)", target.Id()),
+		append(containerIds, dataIds...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, componentLocation := range targetLocations {
+		id, _ := componentLocation["component_id"].(string)
+		transferred[id] = true
+	}
+
+	var items []transferItem
+	var total int64
+	for i, component := range pending {
+		id := ids[i]
+		if !hasMembers[id] {
+			items = append(items, transferItem{component: component, index: indices[i]})
+			continue
+		}
+		for _, member := range members {
+			memberId, _ := member["id"].(string)
+			if member["container_id"] == id && !transferred[memberId] {
+				items = append(items, transferItem{component: member, index: -1})
+			}
+		}
+	}
+	for i := range items {
+		id, _ := componentId(items[i].component)
+		resourceIdentifier, ok := resourceIdentifiers[id]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("component %s is not in location %s", id, source.Name()))
+		}
+		items[i].resourceIdentifier = resourceIdentifier
+		items[i].size = sizes[id]
+		total += sizes[id]
+	}
+
+	transferCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := newProgressTracker(total, options.ProgressInterval, options.OnProgress, options.OnTransferProgress)
+	queue := make(chan transferItem)
+	errs := make(chan error, len(items))
+	var wg sync.WaitGroup
+	for i := 0; i < *options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				result, err := session.transferComponent(transferCtx, item, source, target, tracker)
+				if err != nil {
+					errs <- err
+					cancel()
+					continue
+				}
+				if item.index >= 0 {
+					results[item.index] = result
+				}
+			}
+		}()
+	}
+dispatch:
+	for _, item := range items {
+		select {
+		case queue <- item:
+		case <-transferCtx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return results, err
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+
+	for i, component := range pending {
+		if !hasMembers[ids[i]] || transferred[ids[i]] {
+			continue
+		}
+		result, err := session.addComponent(ctx, component, target, "", nil)
+		if err != nil {
+			return results, err
+		}
+		results[indices[i]] = result
+	}
+	return results, nil
+}
+
+func (session *Session) transferComponent(ctx context.Context, item transferItem, source Location, target Location, tracker *progressTracker) (*CreateResult, error) {
+	sourcePath, _ := source.Accessor().GetFilesystemPath(item.resourceIdentifier)
+	return session.addComponent(ctx, item.component, target, sourcePath, func(resourceIdentifier string) error {
+		reader, err := source.Accessor().Open(ctx, item.resourceIdentifier)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = reader.Close() }()
+		var transferred int64
+		err = target.Accessor().Write(ctx, resourceIdentifier, &progressReader{
+			Reader: reader,
+			Reporter: func(c int64) {
+				transferred += c
+				tracker.Add(c)
+			},
+		}, item.size)
+		if err == nil && transferred != item.size {
+			err = errors.New(fmt.Sprintf("transferred %d bytes of component %v, expected %d", transferred, item.component["id"], item.size))
+		}
+		if err != nil {
+			tracker.Add(-transferred)
+			_ = target.Accessor().Remove(context.Background(), resourceIdentifier)
+		}
+		return err
+	})
+}
@@ -0,0 +1,79 @@
+package ftrack
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSession_TransferComponents(t *testing.T) {
+	sourceDir, err := ioutil.TempDir("", "ftrack-transfer-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sourceDir)
+	targetDir, err := ioutil.TempDir("", "ftrack-transfer-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+	content := []byte("component content")
+	if err := ioutil.WriteFile(filepath.Join(sourceDir, "beauty.exr"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	component := map[string]interface{}{
+		EntityTypeKey:  "FileComponent",
+		"id":           "c0000000-0000-0000-0000-000000000000",
+		"name":         "beauty",
+		"file_type":    ".exr",
+		"container_id": nil,
+	}
+	session, server := newMockSession(t, func(operations []map[string]interface{}) interface{} {
+		var results []interface{}
+		for _, op := range operations {
+			if op["action"] != "query" {
+				results = append(results, map[string]interface{}{"action": op["action"], "data": op["entity_data"]})
+				continue
+			}
+			expression := op["expression"].(string)
+			var data []interface{}
+			switch {
+			case strings.HasPrefix(expression, "select id, size from Component"):
+				data = append(data, map[string]interface{}{"id": component["id"], "size": len(content)})
+			case strings.Contains(expression, "resource_identifier from ComponentLocation") && strings.Contains(expression, `"source"`):
+				data = append(data, map[string]interface{}{"component_id": component["id"], "resource_identifier": "beauty.exr"})
+			}
+			results = append(results, map[string]interface{}{"action": "query", "data": data})
+		}
+		return results
+	})
+	var percents []int
+	onProgress := func(p int) { percents = append(percents, p) }
+	results, err := session.TransferComponents(
+		context.Background(),
+		[]map[string]interface{}{component},
+		NewDiskLocation("source", "source", sourceDir, nil),
+		NewDiskLocation("target", "target", targetDir, nil),
+		TransferComponentsOptions{OnProgress: &onProgress},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, results, 1)
+	assert.Equal(t, "target", results[0].Data["location_id"])
+	resourceIdentifier := results[0].Data["resource_identifier"].(string)
+	transferred, err := ioutil.ReadFile(filepath.Join(targetDir, filepath.FromSlash(resourceIdentifier)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, transferred)
+	assert.Equal(t, 100, percents[len(percents)-1])
+	batches := server.Batches()
+	create := batches[len(batches)-1][0]
+	assert.Equal(t, "create", create["action"])
+	assert.Equal(t, "ComponentLocation", create["entity_type"])
+}
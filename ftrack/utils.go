@@ -1,23 +1,15 @@
 package ftrack
 
 import (
+	"fmt"
 	"golang.org/x/text/unicode/norm"
-	"strings"
+	"net/url"
 )
 
-type uriParameter struct {
-	key   string
-	value string
-}
-
 func NormalizeString(str string) string {
 	return norm.NFC.String(str)
 }
 
-func encodeUriParameters(data ...uriParameter) string {
-	var parts []string
-	for _, p := range data {
-		parts = append(parts, strings.Join([]string{p.key, p.value}, "="))
-	}
-	return strings.Join(parts, "&")
+func buildUrl(serverUrl string, path string, values url.Values) string {
+	return fmt.Sprintf("%s%s?%s", serverUrl, path, values.Encode())
 }
@@ -2,17 +2,18 @@ package ftrack
 
 import (
 	"github.com/stretchr/testify/assert"
+	"net/url"
 	"testing"
 )
 
-func TestEncodeUriParameters(t *testing.T) {
+func TestBuildUrl(t *testing.T) {
 	assert.Equal(t,
-		"id=_id_&username=_username_&apiKey=_apiKey_",
-		encodeUriParameters(
-			uriParameter{"id", "_id_"},
-			uriParameter{"username", "_username_"},
-			uriParameter{"apiKey", "_apiKey_"},
-		),
+		"https://ftrack.example.com/component/get?apiKey=a%2Bb%26c&id=_id_&username=john+doe%40example.com",
+		buildUrl("https://ftrack.example.com", "/component/get", url.Values{
+			"id":       {"_id_"},
+			"username": {"john doe@example.com"},
+			"apiKey":   {"a+b&c"},
+		}),
 	)
 }
 
@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	DefaultStateDirectory = "state"
+	DefaultHealthAddress  = ":8080"
+	DefaultTimeout        = 10 * time.Second
+	DefaultMaxAttempts    = 5
+	DefaultRetryDelay     = time.Second
+	DefaultMaxRetryDelay  = time.Minute
+)
+
+var routeNameExpression = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Duration is a time.Duration read from JSON as a string such as "1m30s" or
+// as a number of seconds.
+type Duration struct {
+	time.Duration
+}
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(duration.String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch casted := value.(type) {
+	case float64:
+		duration.Duration = time.Duration(casted * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(casted)
+		if err != nil {
+			return err
+		}
+		duration.Duration = parsed
+	default:
+		return errors.New(fmt.Sprintf("invalid duration %s", data))
+	}
+	return nil
+}
+
+// Route forwards the events matching Subscription to Url.
+type Route struct {
+	// Name identifies the route in the state directory, the dead letters and
+	// the health report.
+	Name         string `json:"name"`
+	Subscription string `json:"subscription"`
+	Url          string `json:"url"`
+	// Secret signs the requests, SecretEnv names an environment variable to
+	// read it from instead.
+	Secret        string            `json:"secret,omitempty"`
+	SecretEnv     string            `json:"secret_env,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Timeout       Duration          `json:"timeout,omitempty"`
+	MaxAttempts   int               `json:"max_attempts,omitempty"`
+	RetryDelay    Duration          `json:"retry_delay,omitempty"`
+	MaxRetryDelay Duration          `json:"max_retry_delay,omitempty"`
+}
+
+type Config struct {
+	Routes []Route `json:"routes"`
+	// StateDirectory holds the write-ahead logs of the events not delivered
+	// yet, they are delivered after a restart.
+	StateDirectory string `json:"state_directory,omitempty"`
+	// DeadLetterPath is the file events which could not be delivered are
+	// appended to, it defaults to dead-letter.jsonl in the state directory.
+	DeadLetterPath string `json:"dead_letter_path,omitempty"`
+	HealthAddress  string `json:"health_address,omitempty"`
+}
+
+// LoadConfig reads a JSON config file, sets the defaults and validates it.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid config %s: %s", path, err))
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (config *Config) setDefaults() {
+	if config.StateDirectory == "" {
+		config.StateDirectory = DefaultStateDirectory
+	}
+	if config.DeadLetterPath == "" {
+		config.DeadLetterPath = filepath.Join(config.StateDirectory, "dead-letter.jsonl")
+	}
+	if config.HealthAddress == "" {
+		config.HealthAddress = DefaultHealthAddress
+	}
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if route.Secret == "" && route.SecretEnv != "" {
+			route.Secret = os.Getenv(route.SecretEnv)
+		}
+		if route.Timeout.Duration == 0 {
+			route.Timeout.Duration = DefaultTimeout
+		}
+		if route.MaxAttempts == 0 {
+			route.MaxAttempts = DefaultMaxAttempts
+		}
+		if route.RetryDelay.Duration == 0 {
+			route.RetryDelay.Duration = DefaultRetryDelay
+		}
+		if route.MaxRetryDelay.Duration == 0 {
+			route.MaxRetryDelay.Duration = DefaultMaxRetryDelay
+		}
+	}
+}
+
+func (config *Config) Validate() error {
+	if len(config.Routes) == 0 {
+		return errors.New("config has no routes")
+	}
+	names := map[string]bool{}
+	for _, route := range config.Routes {
+		if !routeNameExpression.MatchString(route.Name) {
+			return errors.New(fmt.Sprintf("invalid route name %q", route.Name))
+		}
+		if names[route.Name] {
+			return errors.New(fmt.Sprintf("duplicate route name %q", route.Name))
+		}
+		names[route.Name] = true
+		expression, err := event.ParseExpression(route.Subscription)
+		if err != nil {
+			return errors.New(fmt.Sprintf("route %s: %s", route.Name, err))
+		}
+		// The server only sends the events of the subscribed topics.
+		if len(event.Topics(expression)) == 0 {
+			return errors.New(fmt.Sprintf("route %s: subscription %q does not select a topic", route.Name, route.Subscription))
+		}
+		parsed, err := url.Parse(route.Url)
+		if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+			return errors.New(fmt.Sprintf("route %s: invalid url %q", route.Name, route.Url))
+		}
+		if route.SecretEnv != "" && route.Secret == "" {
+			return errors.New(fmt.Sprintf("route %s: environment variable %s is empty", route.Name, route.SecretEnv))
+		}
+		if route.MaxAttempts < 1 {
+			return errors.New(fmt.Sprintf("route %s: max_attempts must be positive", route.Name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Ftrack-Signature"
+	TimestampHeader = "X-Ftrack-Timestamp"
+	EventIdHeader   = "X-Ftrack-Event-Id"
+	TopicHeader     = "X-Ftrack-Topic"
+	AttemptHeader   = "X-Ftrack-Delivery-Attempt"
+)
+
+const userAgent = "ftrack-golang-api-webhook"
+
+// Sign returns the signature sent in the X-Ftrack-Signature header, the hex
+// encoded HMAC-SHA256 of the timestamp and the body joined with a dot.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + "."))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// DeadLetter is appended to the dead letter file for every event which could
+// not be delivered.
+type DeadLetter struct {
+	Route    string       `json:"route"`
+	Url      string       `json:"url"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failed_at"`
+	Event    *event.Event `json:"event"`
+}
+
+// permanentError is returned for responses retrying cannot fix.
+type permanentError struct {
+	StatusCode int
+}
+
+func (error *permanentError) Error() string {
+	return fmt.Sprintf("endpoint responded with status %d", error.StatusCode)
+}
+
+type RouteStatus struct {
+	Pending       int        `json:"pending"`
+	Delivered     int64      `json:"delivered"`
+	Failed        int64      `json:"failed"`
+	DeadLettered  int64      `json:"dead_lettered"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastDelivered *time.Time `json:"last_delivered,omitempty"`
+}
+
+type Health struct {
+	Status    string                 `json:"status"`
+	Connected bool                   `json:"connected"`
+	Routes    map[string]RouteStatus `json:"routes"`
+}
+
+type forwardRoute struct {
+	Route
+	consumer *event.DurableConsumer
+	status   RouteStatus
+}
+
+type ForwarderOptions struct {
+	Client *http.Client
+	// Connected reports whether the hub is connected, the health endpoint
+	// reports the forwarder as unavailable otherwise.
+	Connected *func() bool
+	// OnError is called for failed delivery attempts.
+	OnError      *func(route string, event *event.Event, err error)
+	OnDeadLetter *func(letter *DeadLetter)
+}
+
+func (options *ForwarderOptions) setDefaults() {
+	if options.Client == nil {
+		options.Client = &http.Client{}
+	}
+	if options.Connected == nil {
+		connected := func() bool { return true }
+		options.Connected = &connected
+	}
+}
+
+// Forwarder posts the events matching the routes of a Config to their
+// endpoints. Events are persisted before delivery and delivered in order per
+// route, failed deliveries are retried with backoff and dead-lettered after
+// the last attempt or when the endpoint rejects them with a client error.
+type Forwarder struct {
+	options ForwarderOptions
+	routes  []*forwardRoute
+	// ctx is the context of Run, deliveries in flight are cancelled with it.
+	ctx        context.Context
+	mu         sync.Mutex
+	deadLetter *os.File
+}
+
+func NewForwarder(config *Config, options ForwarderOptions) (*Forwarder, error) {
+	options.setDefaults()
+	if err := os.MkdirAll(config.StateDirectory, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(config.DeadLetterPath), 0755); err != nil {
+		return nil, err
+	}
+	deadLetter, err := os.OpenFile(config.DeadLetterPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	forwarder := &Forwarder{options: options, ctx: context.Background(), deadLetter: deadLetter}
+	for _, routeConfig := range config.Routes {
+		route := &forwardRoute{Route: routeConfig}
+		onError := func(e *event.Event, err error) {
+			if forwarder.options.OnError != nil {
+				(*forwarder.options.OnError)(route.Name, e, err)
+			}
+		}
+		onDeadLetter := func(e *event.Event, err error) {
+			if err := forwarder.writeDeadLetter(route, e, err); err != nil && forwarder.options.OnError != nil {
+				(*forwarder.options.OnError)(route.Name, e, err)
+			}
+		}
+		retryDelay := route.RetryDelay.Duration
+		maxRetryDelay := route.MaxRetryDelay.Duration
+		maxAttempts := route.MaxAttempts
+		route.consumer, err = event.NewDurableConsumer(
+			filepath.Join(config.StateDirectory, route.Name+".wal"),
+			func(e *event.Event) error { return forwarder.deliver(route, e) },
+			event.DurableConsumerOptions{
+				RetryDelay:    &retryDelay,
+				MaxRetryDelay: &maxRetryDelay,
+				MaxAttempts:   &maxAttempts,
+				OnError:       &onError,
+				OnDeadLetter:  &onDeadLetter,
+			},
+		)
+		if err != nil {
+			_ = forwarder.Close()
+			return nil, err
+		}
+		forwarder.routes = append(forwarder.routes, route)
+	}
+	return forwarder, nil
+}
+
+// Subscribe subscribes every route to hub.
+func (forwarder *Forwarder) Subscribe(hub event.Hub) error {
+	var subscriberIds []string
+	for _, route := range forwarder.routes {
+		subscriberId, err := route.consumer.Subscribe(hub, route.Subscription)
+		if err != nil {
+			for _, subscriberId := range subscriberIds {
+				_ = hub.Unsubscribe(subscriberId)
+			}
+			return errors.New(fmt.Sprintf("route %s: %s", route.Name, err))
+		}
+		subscriberIds = append(subscriberIds, subscriberId)
+	}
+	return nil
+}
+
+// Run delivers the events until ctx is done.
+func (forwarder *Forwarder) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	forwarder.ctx = ctx
+	errs := make(chan error, len(forwarder.routes))
+	for _, route := range forwarder.routes {
+		go func(route *forwardRoute) {
+			err := route.consumer.Run(ctx)
+			if err != nil && err != ctx.Err() {
+				err = errors.New(fmt.Sprintf("route %s: %s", route.Name, err))
+				cancel()
+			}
+			errs <- err
+		}(route)
+	}
+	var result error
+	for range forwarder.routes {
+		if err := <-errs; err != nil && err != context.Canceled && err != context.DeadlineExceeded && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// deliver posts e to the route endpoint. Events the endpoint rejects are
+// dead-lettered right away, the consumer retries the others and dead-letters
+// them after the last attempt.
+func (forwarder *Forwarder) deliver(route *forwardRoute, e *event.Event) error {
+	err := forwarder.post(route, e)
+	forwarder.mu.Lock()
+	if err == nil {
+		now := time.Now()
+		route.status.Delivered++
+		route.status.LastDelivered = &now
+	} else {
+		route.status.Failed++
+		route.status.LastError = err.Error()
+	}
+	forwarder.mu.Unlock()
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return forwarder.writeDeadLetter(route, e, err)
+	}
+	return err
+}
+
+func (forwarder *Forwarder) post(route *forwardRoute, e *event.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(forwarder.ctx, route.Timeout.Duration)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", userAgent)
+	for key, value := range route.Headers {
+		request.Header.Set(key, value)
+	}
+	request.Header.Set(EventIdHeader, e.Id)
+	request.Header.Set(TopicHeader, e.Topic)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(AttemptHeader, strconv.Itoa(route.consumer.Attempt()))
+	if route.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(route.Secret, timestamp, body))
+	}
+	response, err := forwarder.options.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode >= 400 && response.StatusCode < 500 &&
+		response.StatusCode != http.StatusRequestTimeout && response.StatusCode != http.StatusTooManyRequests:
+		return &permanentError{StatusCode: response.StatusCode}
+	}
+	return errors.New(fmt.Sprintf("endpoint responded with status %d", response.StatusCode))
+}
+
+// writeDeadLetter appends e to the dead letter file with the error of its
+// last delivery attempt.
+func (forwarder *Forwarder) writeDeadLetter(route *forwardRoute, e *event.Event, err error) error {
+	letter := &DeadLetter{
+		Route:    route.Name,
+		Url:      route.Url,
+		Attempts: route.consumer.Attempt(),
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+		Event:    e,
+	}
+	encoded, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	forwarder.mu.Lock()
+	if _, err = forwarder.deadLetter.Write(append(encoded, '\n')); err == nil {
+		err = forwarder.deadLetter.Sync()
+	}
+	if err == nil {
+		route.status.DeadLettered++
+	}
+	forwarder.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if forwarder.options.OnDeadLetter != nil {
+		(*forwarder.options.OnDeadLetter)(letter)
+	}
+	return nil
+}
+
+func (forwarder *Forwarder) Health() Health {
+	health := Health{
+		Status:    "ok",
+		Connected: (*forwarder.options.Connected)(),
+		Routes:    map[string]RouteStatus{},
+	}
+	if !health.Connected {
+		health.Status = "unavailable"
+	}
+	forwarder.mu.Lock()
+	defer forwarder.mu.Unlock()
+	for _, route := range forwarder.routes {
+		status := route.status
+		status.Pending = route.consumer.Pending()
+		health.Routes[route.Name] = status
+	}
+	return health
+}
+
+// ServeHTTP reports the health of the forwarder, responding with 503 while
+// the hub is disconnected.
+func (forwarder *Forwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := forwarder.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Connected {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(health)
+}
+
+func (forwarder *Forwarder) Close() error {
+	var err error
+	for _, route := range forwarder.routes {
+		if closeErr := route.consumer.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	if closeErr := forwarder.deadLetter.Close(); closeErr != nil {
+		err = closeErr
+	}
+	return err
+}
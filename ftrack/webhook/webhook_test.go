@@ -0,0 +1,339 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/conducte/ftrack-golang-api/ftrack/event"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ftrack-webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+type delivery struct {
+	header http.Header
+	event  *event.Event
+	body   []byte
+}
+
+// endpoint records the deliveries and responds with the queued status codes,
+// 200 once they are used up.
+type endpoint struct {
+	*httptest.Server
+	mu         sync.Mutex
+	statuses   []int
+	deliveries chan delivery
+}
+
+func newEndpoint(t *testing.T, statuses ...int) *endpoint {
+	endpoint := &endpoint{statuses: statuses, deliveries: make(chan delivery, 100)}
+	endpoint.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		e := &event.Event{}
+		_ = json.Unmarshal(body, e)
+		endpoint.mu.Lock()
+		status := http.StatusOK
+		if len(endpoint.statuses) > 0 {
+			status = endpoint.statuses[0]
+			endpoint.statuses = endpoint.statuses[1:]
+		}
+		endpoint.mu.Unlock()
+		w.WriteHeader(status)
+		endpoint.deliveries <- delivery{header: r.Header, event: e, body: body}
+	}))
+	t.Cleanup(endpoint.Close)
+	return endpoint
+}
+
+func (endpoint *endpoint) next(t *testing.T) delivery {
+	select {
+	case delivery := <-endpoint.deliveries:
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery")
+	}
+	return delivery{}
+}
+
+func newConfig(t *testing.T, routes ...Route) *Config {
+	config := &Config{Routes: routes, StateDirectory: tempDir(t)}
+	for i := range config.Routes {
+		config.Routes[i].RetryDelay = Duration{time.Millisecond}
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func startForwarder(t *testing.T, config *Config, options ForwarderOptions) (*Forwarder, *event.LocalHub) {
+	forwarder, err := NewForwarder(config, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := event.NewLocalHub(event.LocalHubOptions{})
+	if err := forwarder.Subscribe(hub); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- forwarder.Run(ctx) }()
+	t.Cleanup(func() {
+		_ = hub.Close()
+		cancel()
+		<-done
+		_ = forwarder.Close()
+	})
+	return forwarder, hub
+}
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters
+}
+
+func TestForwarder_Deliver(t *testing.T) {
+	assets := newEndpoint(t)
+	all := newEndpoint(t)
+	config := newConfig(t,
+		Route{Name: "assets", Subscription: "topic=ftrack.update and data.kind=asset", Url: assets.URL, Secret: "secret", Headers: map[string]string{"Authorization": "Bearer token"}},
+		Route{Name: "all", Subscription: "topic=ftrack.*", Url: all.URL},
+	)
+	_, hub := startForwarder(t, config, ForwarderOptions{})
+
+	_, err := hub.Publish(event.NewEvent("ftrack.update", map[string]interface{}{"kind": "asset"}))
+	assert.Nil(t, err)
+	_, err = hub.Publish(event.NewEvent("ftrack.update", map[string]interface{}{"kind": "shot"}))
+	assert.Nil(t, err)
+
+	delivery := assets.next(t)
+	assert.Equal(t, "asset", delivery.event.Data["kind"])
+	assert.Equal(t, delivery.event.Id, delivery.header.Get(EventIdHeader))
+	assert.Equal(t, "ftrack.update", delivery.header.Get(TopicHeader))
+	assert.Equal(t, "1", delivery.header.Get(AttemptHeader))
+	assert.Equal(t, "Bearer token", delivery.header.Get("Authorization"))
+	assert.True(t, Verify("secret", delivery.header.Get(TimestampHeader), delivery.body, delivery.header.Get(SignatureHeader)))
+	assert.False(t, Verify("other", delivery.header.Get(TimestampHeader), delivery.body, delivery.header.Get(SignatureHeader)))
+
+	assert.Equal(t, "asset", all.next(t).event.Data["kind"])
+	second := all.next(t)
+	assert.Equal(t, "shot", second.event.Data["kind"])
+	assert.Empty(t, second.header.Get(SignatureHeader))
+}
+
+func TestForwarder_Retry(t *testing.T) {
+	endpoint := newEndpoint(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	var mu sync.Mutex
+	var failures []string
+	onError := func(route string, e *event.Event, err error) {
+		mu.Lock()
+		failures = append(failures, route+": "+err.Error())
+		mu.Unlock()
+	}
+	config := newConfig(t, Route{Name: "retry", Subscription: "topic=test", Url: endpoint.URL})
+	forwarder, hub := startForwarder(t, config, ForwarderOptions{OnError: &onError})
+
+	_, err := hub.Publish(event.NewEvent("test", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", endpoint.next(t).header.Get(AttemptHeader))
+	assert.Equal(t, "2", endpoint.next(t).header.Get(AttemptHeader))
+	assert.Equal(t, "3", endpoint.next(t).header.Get(AttemptHeader))
+
+	assert.Eventually(t, func() bool {
+		return forwarder.Health().Routes["retry"].Delivered == 1
+	}, 5*time.Second, time.Millisecond)
+	status := forwarder.Health().Routes["retry"]
+	assert.Equal(t, int64(2), status.Failed)
+	assert.Equal(t, 0, status.Pending)
+	mu.Lock()
+	assert.Equal(t, []string{"retry: endpoint responded with status 500", "retry: endpoint responded with status 429"}, failures)
+	mu.Unlock()
+}
+
+func TestForwarder_DeadLetter(t *testing.T) {
+	endpoint := newEndpoint(t, http.StatusBadRequest, http.StatusBadGateway, http.StatusBadGateway)
+	letters := make(chan *DeadLetter, 10)
+	onDeadLetter := func(letter *DeadLetter) { letters <- letter }
+	config := newConfig(t, Route{Name: "failing", Subscription: "topic=test", Url: endpoint.URL, MaxAttempts: 2})
+	forwarder, hub := startForwarder(t, config, ForwarderOptions{OnDeadLetter: &onDeadLetter})
+
+	rejected := event.NewEvent("test", nil)
+	_, err := hub.Publish(rejected)
+	assert.Nil(t, err)
+	unavailable := event.NewEvent("test", nil)
+	_, err = hub.Publish(unavailable)
+	assert.Nil(t, err)
+	delivered := event.NewEvent("test", nil)
+	_, err = hub.Publish(delivered)
+	assert.Nil(t, err)
+
+	// The rejected event is dead-lettered right away, the other one after
+	// its last attempt.
+	assert.Equal(t, rejected.Id, endpoint.next(t).event.Id)
+	assert.Equal(t, unavailable.Id, endpoint.next(t).event.Id)
+	assert.Equal(t, unavailable.Id, endpoint.next(t).event.Id)
+	assert.Equal(t, delivered.Id, endpoint.next(t).event.Id)
+	assert.Equal(t, rejected.Id, (<-letters).Event.Id)
+	assert.Equal(t, unavailable.Id, (<-letters).Event.Id)
+
+	written := readDeadLetters(t, config.DeadLetterPath)
+	assert.Len(t, written, 2)
+	assert.Equal(t, "failing", written[0].Route)
+	assert.Equal(t, 1, written[0].Attempts)
+	assert.Equal(t, "endpoint responded with status 400", written[0].Error)
+	assert.Equal(t, 2, written[1].Attempts)
+	assert.Equal(t, unavailable.Id, written[1].Event.Id)
+	assert.Equal(t, int64(2), forwarder.Health().Routes["failing"].DeadLettered)
+}
+
+func TestForwarder_Restart(t *testing.T) {
+	endpoint := newEndpoint(t)
+	config := newConfig(t, Route{Name: "restart", Subscription: "topic=test", Url: endpoint.URL})
+	forwarder, err := NewForwarder(config, ForwarderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := event.NewLocalHub(event.LocalHubOptions{})
+	assert.Nil(t, forwarder.Subscribe(hub))
+	published := event.NewEvent("test", nil)
+	_, err = hub.Publish(published)
+	assert.Nil(t, err)
+	hub.Wait()
+	assert.Nil(t, forwarder.Close())
+
+	// Events received before the restart are delivered by the next process.
+	startForwarder(t, config, ForwarderOptions{})
+	assert.Equal(t, published.Id, endpoint.next(t).event.Id)
+}
+
+func TestForwarder_Shutdown(t *testing.T) {
+	received := make(chan struct{}, 1)
+	blocked := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-blocked
+	}))
+	defer endpoint.Close()
+	defer close(blocked)
+	config := newConfig(t, Route{Name: "slow", Subscription: "topic=test", Url: endpoint.URL, Timeout: Duration{time.Minute}})
+	forwarder, err := NewForwarder(config, ForwarderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = forwarder.Close() }()
+	hub := event.NewLocalHub(event.LocalHubOptions{})
+	defer func() { _ = hub.Close() }()
+	assert.Nil(t, forwarder.Subscribe(hub))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- forwarder.Run(ctx) }()
+	_, err = hub.Publish(event.NewEvent("test", nil))
+	assert.Nil(t, err)
+	<-received
+
+	// Shutting down cancels the delivery in flight and keeps the event.
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	assert.Equal(t, 1, forwarder.Health().Routes["slow"].Pending)
+	assert.Equal(t, int64(0), forwarder.Health().Routes["slow"].DeadLettered)
+}
+
+func TestForwarder_ServeHTTP(t *testing.T) {
+	endpoint := newEndpoint(t)
+	connected := false
+	isConnected := func() bool { return connected }
+	config := newConfig(t, Route{Name: "health", Subscription: "topic=test", Url: endpoint.URL})
+	forwarder, _ := startForwarder(t, config, ForwarderOptions{Connected: &isConnected})
+
+	recorder := httptest.NewRecorder()
+	forwarder.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	var health Health
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &health))
+	assert.Equal(t, "unavailable", health.Status)
+	assert.Contains(t, health.Routes, "health")
+
+	connected = true
+	recorder = httptest.NewRecorder()
+	forwarder.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &health))
+	assert.Equal(t, "ok", health.Status)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "config.json")
+	assert.Nil(t, os.Setenv("FTRACK_WEBHOOK_TEST_SECRET", "from-env"))
+	defer func() { _ = os.Unsetenv("FTRACK_WEBHOOK_TEST_SECRET") }()
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+		"routes": [{
+			"name": "assets",
+			"subscription": "topic=ftrack.update",
+			"url": "https://example.com/hook",
+			"secret_env": "FTRACK_WEBHOOK_TEST_SECRET",
+			"timeout": "30s",
+			"retry_delay": 2
+		}]
+	}`), 0644))
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := config.Routes[0]
+	assert.Equal(t, "from-env", route.Secret)
+	assert.Equal(t, 30*time.Second, route.Timeout.Duration)
+	assert.Equal(t, 2*time.Second, route.RetryDelay.Duration)
+	assert.Equal(t, DefaultMaxAttempts, route.MaxAttempts)
+	assert.Equal(t, filepath.Join(DefaultStateDirectory, "dead-letter.jsonl"), config.DeadLetterPath)
+	assert.Equal(t, DefaultHealthAddress, config.HealthAddress)
+
+	for _, invalid := range []string{
+		`{"routes": []}`,
+		`{"routes": [{"name": "a/b", "subscription": "topic=a", "url": "http://example.com"}]}`,
+		`{"routes": [{"name": "a", "subscription": "topic=", "url": "http://example.com"}]}`,
+		`{"routes": [{"name": "a", "subscription": "data.kind=asset", "url": "http://example.com"}]}`,
+		`{"routes": [{"name": "a", "subscription": "topic!=a", "url": "http://example.com"}]}`,
+		`{"routes": [{"name": "a", "subscription": "topic=a", "url": "ftp://example.com"}]}`,
+		`{"routes": [{"name": "a", "subscription": "topic=a", "url": "http://example.com", "timeout": "soon"}]}`,
+		`{"routes": [{"name": "a", "subscription": "topic=a", "url": "http://a"}, {"name": "a", "subscription": "topic=a", "url": "http://a"}]}`,
+	} {
+		assert.Nil(t, ioutil.WriteFile(path, []byte(invalid), 0644))
+		_, err := LoadConfig(path)
+		assert.NotNil(t, err, invalid)
+	}
+}